@@ -1,52 +1,180 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
+	"time"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/debug"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/eslip"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/featureflag"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/health"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tlsconfig"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
 )
 
 type Server struct {
 	*echo.Echo
+	probes     *health.Probes
+	drainDelay time.Duration
 }
 
-func New(db *sql.DB, cfg config.Config, logger *zap.Logger) *Server {
+func New(db *sql.DB, cfg config.Config, logger *zap.Logger, levels *mlog.Levels) *Server {
 	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler
 
-	e.Use(middleware.Logger())
+	e.Use(metrics.Middleware())
+	if cfg.Tracing.Enabled {
+		// before mlog, so request logs carry the span's trace id
+		e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+	}
 	e.Use(mlog.Middleware(logger))
+	e.Use(mlog.Sample(cfg.Log))
+	e.Use(mlog.AccessLog(cfg.AccessLog))
+	e.Use(database.Deadline(cfg.Database.QueryTimeout))
+
+	if err := metrics.RegisterDB(db, "hongjot"); err != nil {
+		logger.Fatal("failed to register database metrics", zap.Error(err))
+	}
+
+	probes := health.New(cfg.Health.CheckTimeout, health.Database(db))
+	if cfg.Database.Driver != "ramsql" {
+		// ramsql is migrated before the server starts and keeps no version
+		probes.Add(health.Checker{Name: "migrations", Check: migration.Applied(db)})
+	}
+	e.GET("/livez", probes.Live)
+	e.GET("/readyz", probes.Ready)
+	e.GET("/startupz", probes.Startup)
 
 	v1 := e.Group("/api/v1")
 
-	v1.GET("/slow", health.Slow)
-	v1.GET("/health", health.Check(db))
-	v1.POST("/upload", eslip.Upload)
+	v1.GET("/health", probes.Ready)
+
+	flags, err := featureflag.NewProvider(cfg.FeatureFlag, db)
+	if err != nil {
+		logger.Fatal("failed to create feature flag provider", zap.Error(err))
+	}
+
+	gate := featureflag.NewGate(featureflag.NewEvaluator(flags, cfg.FeatureFlag.Environment))
+
+	{
+		h := featureflag.New(flags)
+		admin := v1.Group("/admin", auth.Admin(cfg.Auth.AdminToken))
+		admin.GET("/flags", h.GetAll)
+		admin.PUT("/flags", h.Update)
+		admin.GET("/flags/routes", gate.ListRoutes)
+		admin.GET("/db/stats", database.Stats(db))
+		admin.GET("/log/level", levels.Get)
+		admin.PUT("/log/level", levels.Update)
+		if cfg.Debug.Enabled {
+			debug.Register(admin.Group("/debug"))
+		}
+
+		effective := cfg.Redacted()
+		admin.GET("/config", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, effective)
+		})
+	}
+
+	{
+		store, err := eslip.NewStorage(context.Background(), cfg.Storage)
+		if err != nil {
+			logger.Fatal("failed to create slip storage", zap.Error(err))
+		}
+		probes.Add(health.Checker{Name: "storage", Check: store.Ping})
+		if local, ok := store.(*eslip.LocalStorage); ok {
+			e.PUT(eslip.LocalUploadPath+"/*", local.ReceiveUpload)
+		}
+
+		scanner, err := eslip.NewScanner(cfg.Scanner)
+		if err != nil {
+			logger.Fatal("failed to create malware scanner", zap.Error(err))
+		}
+
+		h := eslip.New(db, store, scanner, cfg.Storage.PresignTTL)
+		spender := auth.Spender(cfg.Auth.Secret)
+		v1.POST("/upload", h.Upload, spender)
+		v1.POST("/slips/presign", h.Presign, spender)
+		v1.POST("/slips/:id/complete", h.Complete, spender)
+		v1.GET("/slips/:id", h.Download, spender)
+		v1.GET("/slips/:id/thumbnail", h.Thumbnail, spender)
+	}
+
+	spenders := spender.NewPostgresStore(db)
+	transactions := transaction.NewPostgresStore(db)
+	if cfg.Database.Driver == "ramsql" {
+		spenders = spender.NewRamSQLStore(db)
+		transactions = transaction.NewRamSQLStore(db)
+	}
 
 	{
-		h := spender.New(cfg.FeatureFlag, db)
+		h := spender.New(spenders, transactions)
 		v1.GET("/spenders", h.GetAll)
 		v1.GET("/spenders/:id", h.Get)
-		v1.POST("/spenders", h.Create)
-		v1.PUT("/spenders/:id", h.Update)
+		// a new spender has no id yet, so only rules that target everyone apply
+		gate.Add(v1, http.MethodPost, "/spenders", h.Create, featureflag.CreateSpender, featureflag.NoSpender)
+		gate.Add(v1, http.MethodPut, "/spenders/:id", h.Update, featureflag.UpdateSpender, featureflag.SpenderParam("id"))
 		v1.GET("/spenders/:id/transactions", h.GetTransactions)
 		v1.GET("/spenders/:id/transections/summary", h.GetSummary)
 	}
 
 	{
-		h := transaction.New(cfg.FeatureFlag, db)
+		h := transaction.New(transactions)
 		v1.GET("/transactions", h.GetAll)
-		v1.POST("/transactions", h.Create)
+		gate.Add(v1, http.MethodPost, "/transactions", h.Create, featureflag.CreateTransaction, featureflag.SpenderField("spender_id"))
 		v1.GET("/transactions/:id", h.Get)
-		v1.PUT("/transactions/:id", h.Update)
+		gate.Add(v1, http.MethodPut, "/transactions/:id", h.Update, featureflag.UpdateTransaction, featureflag.SpenderField("spender_id"))
 	}
 
-	return &Server{e}
+	for _, r := range gate.Routes() {
+		logger.Info("route gated by feature flag", zap.String("method", r.Method), zap.String("path", r.Path), zap.String("flag", r.Flag))
+	}
+
+	return &Server{Echo: e, probes: probes, drainDelay: cfg.Health.DrainDelay}
+}
+
+// Shutdown fails readiness and waits out the drain delay, so load balancers
+// stop sending traffic, before it stops the server gracefully.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.probes.Drain()
+	select {
+	case <-time.After(s.drainDelay):
+	case <-ctx.Done():
+	}
+	return s.Echo.Shutdown(ctx)
+}
+
+// Serve listens on cfg.Port, terminating TLS itself when cfg.TLS asks for
+// it. HTTP/2 is negotiated over TLS, or spoken as h2c on a plain listener.
+func (s *Server) Serve(cfg config.Server, logger *zap.Logger) error {
+	addr := ":" + cfg.Port
+
+	tlsCfg, err := tlsconfig.New(cfg.TLS, cfg.HTTP2, logger)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case tlsCfg != nil:
+		s.TLSServer.Addr = addr
+		s.TLSServer.TLSConfig = tlsCfg
+		return s.StartServer(s.TLSServer)
+	case cfg.HTTP2:
+		return s.StartH2CServer(addr, &http2.Server{})
+	default:
+		return s.Start(addr)
+	}
 }
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Run("should return subject for a valid token", func(t *testing.T) {
+		token := Sign("secret", "1")
+
+		subject, err := Verify("secret", token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", subject)
+	})
+
+	t.Run("should reject token signed with another secret", func(t *testing.T) {
+		token := Sign("other", "1")
+
+		_, err := Verify("secret", token)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("should reject any token when secret is empty", func(t *testing.T) {
+		token := Sign("", "1")
+
+		_, err := Verify("", token)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("should reject malformed token", func(t *testing.T) {
+		_, err := Verify("secret", "no-signature")
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestSpenderMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		auth           string
+		wantStatusCode int
+	}{
+		{"valid token", "Bearer " + Sign("secret", "7"), http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong signature", "Bearer " + Sign("wrong", "7"), http.StatusUnauthorized},
+		{"non int subject", "Bearer " + Sign("secret", "admin"), http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = problem.Handler
+			defer e.Close()
+
+			var got int64
+			e.GET("/", func(c echo.Context) error {
+				got, _ = SpenderID(c)
+				return c.NoContent(http.StatusOK)
+			}, Spender("secret"))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.auth != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.auth)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+			if tc.wantStatusCode == http.StatusOK {
+				assert.Equal(t, int64(7), got)
+			}
+		})
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		auth           string
+		wantStatusCode int
+	}{
+		{"valid token", "admin-token", "Bearer admin-token", http.StatusOK},
+		{"missing header", "admin-token", "", http.StatusUnauthorized},
+		{"wrong token", "admin-token", "Bearer nope", http.StatusUnauthorized},
+		{"admin disabled", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = problem.Handler
+			defer e.Close()
+
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, Admin(tc.token))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.auth != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.auth)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+		})
+	}
+}
@@ -1,3 +1,13 @@
+// Package config loads the service configuration from, in increasing order
+// of precedence:
+//
+//  1. built-in defaults (see Default)
+//  2. a YAML or JSON file named by --config or CONFIG_FILE
+//  3. environment variables, prefixed with "<ENV>_" when ENV is set
+//  4. command line flags such as --server.port=8081
+//
+// Every layer only overrides the values it sets. The result is validated and
+// all problems are reported together.
 package config
 
 import (
@@ -5,34 +15,287 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/caarlos0/env/v10"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Database    Database
-	Server      Server
-	FeatureFlag FeatureFlag
+	// Env is the deployment name given as the ENV prefix, e.g. "LOCAL".
+	Env         string      `yaml:"-"`
+	Database    Database    `yaml:"database"`
+	Server      Server      `yaml:"server"`
+	FeatureFlag FeatureFlag `yaml:"feature_flag"`
+	Auth        Auth        `yaml:"auth"`
+	Storage     Storage     `yaml:"storage"`
+	Scanner     Scanner     `yaml:"scanner"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Metrics     Metrics     `yaml:"metrics"`
+	AccessLog   AccessLog   `yaml:"access_log"`
+	Log         Log         `yaml:"log"`
+	Health      Health      `yaml:"health"`
+	Debug       Debug       `yaml:"debug"`
 }
 
 func (c Config) PostgresURI() string {
 	return c.Database.PostgresURI
 }
 
+// Server configures the listener. HTTP2 serves HTTP/2 over TLS, or h2c
+// when TLS is off.
 type Server struct {
-	Port string `env:"SERVER_PORT"`
+	Port  string `env:"SERVER_PORT" yaml:"port"`
+	HTTP2 bool   `env:"SERVER_HTTP2" yaml:"http2"`
+	TLS   TLS    `yaml:"tls"`
 }
 
+// TLS lets the server terminate TLS itself where there is no ingress. Mode
+// is "off", "file" (CertFile and KeyFile, reloaded when they change on disk)
+// or "self-signed" for development. ClientCAFile turns on mutual TLS: only
+// clients with a certificate signed by one of its CAs may connect.
+type TLS struct {
+	Mode         string `env:"SERVER_TLS_MODE" yaml:"mode"`
+	CertFile     string `env:"SERVER_TLS_CERT_FILE" yaml:"cert_file"`
+	KeyFile      string `env:"SERVER_TLS_KEY_FILE" yaml:"key_file"`
+	ClientCAFile string `env:"SERVER_TLS_CLIENT_CA_FILE" yaml:"client_ca_file"`
+}
+
+// Database configures the connection pool. QueryTimeout is the deadline
+// every handler gives its queries; StatementTimeout is also enforced by
+// Postgres itself when set. Turn AutoMigrate off when migrations run as a
+// separate step, e.g. "app migrate up" in a Kubernetes job. Replicas take
+// turns migrating under an advisory lock and give up after MigrateTimeout.
+// Seed names the demo data set "app seed" loads, e.g. "dev"; leave it empty
+// in production. Driver "ramsql" swaps Postgres for an in-process database
+// that starts empty on every boot, for local development without a server.
 type Database struct {
-	PostgresURI string `env:"DATABASE_POSTGRES_URI,required"`
+	Driver           string        `env:"DATABASE_DRIVER" yaml:"driver"`
+	PostgresURI      string        `env:"DATABASE_POSTGRES_URI" yaml:"postgres_uri" secret:"uri"`
+	MaxOpenConns     int           `env:"DATABASE_MAX_OPEN_CONNS" yaml:"max_open_conns"`
+	MaxIdleConns     int           `env:"DATABASE_MAX_IDLE_CONNS" yaml:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `env:"DATABASE_CONN_MAX_LIFETIME" yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `env:"DATABASE_CONN_MAX_IDLE_TIME" yaml:"conn_max_idle_time"`
+	QueryTimeout     time.Duration `env:"DATABASE_QUERY_TIMEOUT" yaml:"query_timeout"`
+	StatementTimeout time.Duration `env:"DATABASE_STATEMENT_TIMEOUT" yaml:"statement_timeout"`
+	AutoMigrate      bool          `env:"DATABASE_AUTO_MIGRATE" yaml:"auto_migrate"`
+	MigrateTimeout   time.Duration `env:"DATABASE_MIGRATE_TIMEOUT" yaml:"migrate_timeout"`
+	Seed             string        `env:"DATABASE_SEED" yaml:"seed"`
 }
 
+// FeatureFlag holds the initial value of every flag. Backend selects where
+// flags are read at runtime: "env" (these values, changeable in memory),
+// "file" (a JSON object in File) or "db" (the feature_flag table).
+// Environment names the deployment that environments rules match. It
+// defaults to ENV, so set it where ENV is left empty to keep env names bare.
 type FeatureFlag struct {
-	EnableCreateSpender bool `env:"ENABLE_CREATE_SPENDER"`
-	EnableCreateTransaction bool `env:"ENABLE_CREATE_TRANSACTION"`
-	EnableUpdateSpender bool `env:"ENABLE_UPDATE_SPENDER"`
-	EnableUpdateTransaction bool `env:"ENABLE_UPDATE_TRANSACTION"`
+	EnableCreateSpender     bool   `env:"ENABLE_CREATE_SPENDER" yaml:"enable_create_spender"`
+	EnableCreateTransaction bool   `env:"ENABLE_CREATE_TRANSACTION" yaml:"enable_create_transaction"`
+	EnableUpdateSpender     bool   `env:"ENABLE_UPDATE_SPENDER" yaml:"enable_update_spender"`
+	EnableUpdateTransaction bool   `env:"ENABLE_UPDATE_TRANSACTION" yaml:"enable_update_transaction"`
+	Backend                 string `env:"FEATURE_FLAG_BACKEND" yaml:"backend"`
+	File                    string `env:"FEATURE_FLAG_FILE" yaml:"file"`
+	Environment             string `env:"FEATURE_FLAG_ENVIRONMENT" yaml:"environment"`
+}
+
+// Auth holds the secret used to sign and verify spender access tokens and
+// the static token required by admin endpoints.
+type Auth struct {
+	Secret     string `env:"AUTH_SECRET" yaml:"secret" secret:"true"`
+	AdminToken string `env:"AUTH_ADMIN_TOKEN" yaml:"admin_token" secret:"true"`
+}
+
+// Storage configures where uploaded slips are kept. Backend is either
+// "local" (files under LocalRoot) or "s3" for any S3-compatible service.
+// S3AccessKey and S3SecretKey override the AWS default credential chain.
+type Storage struct {
+	Backend       string        `env:"STORAGE_BACKEND" yaml:"backend"`
+	LocalRoot     string        `env:"STORAGE_LOCAL_ROOT" yaml:"local_root"`
+	PresignSecret string        `env:"STORAGE_PRESIGN_SECRET" yaml:"presign_secret" secret:"true"`
+	PresignTTL    time.Duration `env:"STORAGE_PRESIGN_TTL" yaml:"presign_ttl"`
+	S3Bucket      string        `env:"STORAGE_S3_BUCKET" yaml:"s3_bucket"`
+	S3Region      string        `env:"STORAGE_S3_REGION" yaml:"s3_region"`
+	S3Endpoint    string        `env:"STORAGE_S3_ENDPOINT" yaml:"s3_endpoint"`
+	S3AccessKey   string        `env:"STORAGE_S3_ACCESS_KEY" yaml:"s3_access_key" secret:"true"`
+	S3SecretKey   string        `env:"STORAGE_S3_SECRET_KEY" yaml:"s3_secret_key" secret:"true"`
+	S3PathStyle   bool          `env:"STORAGE_S3_PATH_STYLE" yaml:"s3_path_style"`
+}
+
+// Scanner selects the malware scanner run on uploads: "none" or "clamd".
+type Scanner struct {
+	Backend   string        `env:"SCANNER_BACKEND" yaml:"backend"`
+	ClamdAddr string        `env:"SCANNER_CLAMD_ADDR" yaml:"clamd_addr"`
+	Timeout   time.Duration `env:"SCANNER_TIMEOUT" yaml:"timeout"`
+}
+
+// Tracing exports OpenTelemetry spans over OTLP/HTTP to Endpoint, the
+// host:port of a collector. Insecure sends them without TLS, which is what a
+// local collector usually expects.
+type Tracing struct {
+	Enabled     bool   `env:"TRACING_ENABLED" yaml:"enabled"`
+	Endpoint    string `env:"TRACING_OTLP_ENDPOINT" yaml:"otlp_endpoint"`
+	Insecure    bool   `env:"TRACING_OTLP_INSECURE" yaml:"otlp_insecure"`
+	ServiceName string `env:"TRACING_SERVICE_NAME" yaml:"service_name"`
+}
+
+// Metrics serves Prometheus metrics on /metrics at Port, an admin port kept
+// apart from the public API. An empty Port turns it off.
+type Metrics struct {
+	Port string `env:"METRICS_PORT" yaml:"port"`
+}
+
+// AccessLog configures the line logged for every request. With Body on, JSON
+// request and response bodies up to MaxBody bytes are logged as well, masked
+// by Redact: comma separated field:mode rules such as "email:email,name:partial".
+// A mode is full, partial (first letter kept) or email (first letter and
+// domain kept). Fields match at any depth, ignoring case.
+type AccessLog struct {
+	Body    bool   `env:"ACCESS_LOG_BODY" yaml:"body"`
+	MaxBody int    `env:"ACCESS_LOG_MAX_BODY" yaml:"max_body"`
+	Redact  string `env:"ACCESS_LOG_REDACT" yaml:"redact"`
+}
+
+// RedactModes are the modes a Redact rule may use.
+var RedactModes = []string{"full", "partial", "email"}
+
+// Rules parses Redact into lower-cased field names and their modes.
+func (a AccessLog) Rules() (map[string]string, error) {
+	rules := map[string]string{}
+	for _, rule := range strings.Split(a.Redact, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		field, mode, ok := strings.Cut(rule, ":")
+		field, mode = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(mode)
+		if !ok || field == "" || !slices.Contains(RedactModes, mode) {
+			return nil, fmt.Errorf("%q is not a field:mode rule with mode one of %s", rule, strings.Join(RedactModes, ", "))
+		}
+		rules[field] = mode
+	}
+	return rules, nil
+}
+
+// Log sets the starting log level. Packages overrides it per package, e.g.
+// "spender=debug,mlog=warn", and both can be changed at runtime through the
+// admin API. Sample keeps only one in N requests of a busy route, e.g.
+// "GET /api/v1/spenders=100"; the others still log warnings and errors.
+type Log struct {
+	Level    string `env:"LOG_LEVEL" yaml:"level"`
+	Packages string `env:"LOG_PACKAGES" yaml:"packages"`
+	Sample   string `env:"LOG_SAMPLE" yaml:"sample"`
+}
+
+// PackageLevels parses Packages.
+func (l Log) PackageLevels() (map[string]zapcore.Level, error) {
+	levels := map[string]zapcore.Level{}
+	for name, value := range pairs(l.Packages) {
+		level, err := zapcore.ParseLevel(value)
+		if err != nil || name == "" || value == "" {
+			return nil, fmt.Errorf("%q is not a package=level rule", name+"="+value)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// SampleRules parses Sample into "METHOD /route" keys and their N.
+func (l Log) SampleRules() (map[string]int, error) {
+	rules := map[string]int{}
+	for route, value := range pairs(l.Sample) {
+		n, err := strconv.Atoi(value)
+		method, path, ok := strings.Cut(route, " ")
+		if err != nil || n < 1 || !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("%q is not a \"METHOD /route=N\" rule with N of at least 1", route+"="+value)
+		}
+		rules[route] = n
+	}
+	return rules, nil
+}
+
+// pairs splits "a=1,b=2" into a map, ignoring blank items.
+func pairs(s string) map[string]string {
+	out := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(item, "=")
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// Health tunes the probes. Every readiness check gets CheckTimeout. On
+// shutdown /readyz fails for DrainDelay before the server stops, so load
+// balancers take the pod out of rotation first.
+type Health struct {
+	CheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT" yaml:"check_timeout"`
+	DrainDelay   time.Duration `env:"HEALTH_DRAIN_DELAY" yaml:"drain_delay"`
+}
+
+// Debug mounts pprof, expvar and the demo endpoints under the admin API.
+// Keep it off in production unless you are chasing a problem.
+type Debug struct {
+	Enabled bool `env:"DEBUG_ENABLED" yaml:"enabled"`
+}
+
+// Default is the bottom layer every other source overrides.
+func Default() Config {
+	return Config{
+		Database: Database{
+			Driver:          "postgres",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			QueryTimeout:    5 * time.Second,
+			AutoMigrate:     true,
+			MigrateTimeout:  5 * time.Minute,
+		},
+		Server: Server{
+			Port: "8080",
+			TLS:  TLS{Mode: "off"},
+		},
+		FeatureFlag: FeatureFlag{
+			Backend: "env",
+			File:    "flags.json",
+		},
+		Storage: Storage{
+			Backend:    "local",
+			LocalRoot:  "slips",
+			PresignTTL: 15 * time.Minute,
+		},
+		Scanner: Scanner{
+			Backend:   "none",
+			ClamdAddr: "localhost:3310",
+			Timeout:   30 * time.Second,
+		},
+		Tracing: Tracing{
+			Endpoint:    "localhost:4318",
+			ServiceName: "hongjot",
+		},
+		Metrics: Metrics{
+			Port: "9464",
+		},
+		AccessLog: AccessLog{
+			MaxBody: 4096,
+			Redact:  "email:email,name:partial,amount:full",
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Health: Health{
+			CheckTimeout: 2 * time.Second,
+			DrainDelay:   5 * time.Second,
+		},
+	}
 }
 
 func Env(key string) string {
@@ -59,42 +322,74 @@ func prefix(env string) string {
 	return ""
 }
 
-func parse(envPrefix string) (Config, error) {
-	opts := env.Options{
-		Prefix: prefix(envPrefix),
+// loadFile decodes a config file over cfg. JSON is valid YAML, so .json
+// files go through the same decoder.
+func loadFile(cfg *Config, path string) error {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .json", path)
 	}
 
-	dbconf := &Database{}
-	if err := env.ParseWithOptions(dbconf, opts); err != nil {
-		return Config{}, errors.New("failed to parse database config:" + err.Error())
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	feats := &FeatureFlag{}
-	if err := env.ParseWithOptions(feats, opts); err != nil {
-		return Config{}, errors.New("failed to parse feature flag config:" + err.Error())
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
+	return nil
+}
+
+// Load builds the configuration from every layer. args are the command line
+// arguments without the program name; parsing stops at the first argument
+// that is not a flag. All problems are returned joined in one error.
+func Load(envPrefix string, args []string) (Config, error) {
+	cfg := Default()
+	cfg.Env = envPrefix
 
-	port := Env("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	flags := newFlags()
+	if err := flags.fs.Parse(args); err != nil {
+		return Config{}, err
 	}
 
-	return Config{
-		Database: Database{
-			PostgresURI: dbconf.PostgresURI,
-		},
-		Server: Server{
-			Port: port,
-		},
-		FeatureFlag: FeatureFlag{
-			EnableCreateSpender: feats.EnableCreateSpender,
-			EnableCreateTransaction: feats.EnableCreateTransaction,
-			EnableUpdateSpender: feats.EnableUpdateSpender,
-			EnableUpdateTransaction: feats.EnableUpdateTransaction,
-		},
-	}, nil
+	var errs []error
+
+	path := flags.file
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := env.ParseWithOptions(&cfg, env.Options{Prefix: prefix(envPrefix)}); err != nil {
+		errs = append(errs, errors.New("failed to parse env config:"+err.Error()))
+	}
+
+	if err := flags.apply(&cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.FeatureFlag.Environment == "" {
+		cfg.FeatureFlag.Environment = envPrefix
+	}
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+func parse(envPrefix string) (Config, error) {
+	return Load(envPrefix, nil)
 }
 
+// Parse loads the configuration once from defaults, file and env. Use Load
+// to also read command line flags.
 func Parse(envPrefix string) Config {
 	once.Do(func() {
 		var err error
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rawFlag keeps the text of a command line flag until the layers below it
+// have been loaded.
+type rawFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *rawFlag) String() string     { return f.value }
+func (f *rawFlag) Set(v string) error { f.value = v; return nil }
+func (f *rawFlag) IsBoolFlag() bool   { return f.isBool }
+
+type cliFlags struct {
+	fs   *flag.FlagSet
+	file string
+	raw  map[string]*rawFlag
+}
+
+// newFlags declares --config plus one flag per config field, named after
+// its yaml path, e.g. --storage.presign_ttl.
+func newFlags() *cliFlags {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags := &cliFlags{fs: fs, raw: map[string]*rawFlag{}}
+
+	fs.StringVar(&flags.file, "config", "", "YAML or JSON config file")
+	walk(reflect.ValueOf(&Config{}).Elem(), "", func(name string, f reflect.StructField, _ reflect.Value) {
+		raw := &rawFlag{isBool: f.Type.Kind() == reflect.Bool}
+		flags.raw[name] = raw
+		fs.Var(raw, name, "overrides env "+f.Tag.Get("env"))
+	})
+
+	return flags
+}
+
+// walk calls fn for every leaf field with a yaml name.
+func walk(v reflect.Value, parent string, fn func(name string, f reflect.StructField, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := tag
+		if parent != "" {
+			name = parent + "." + tag
+		}
+
+		if f.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), name, fn)
+			continue
+		}
+		fn(name, f, v.Field(i))
+	}
+}
+
+// apply sets the fields of cfg whose flag was given on the command line.
+func (flags *cliFlags) apply(cfg *Config) error {
+	set := map[string]bool{}
+	flags.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var errs []error
+	walk(reflect.ValueOf(cfg).Elem(), "", func(name string, _ reflect.StructField, v reflect.Value) {
+		if !set[name] {
+			return
+		}
+		if err := setValue(v, flags.raw[name].value); err != nil {
+			errs = append(errs, fmt.Errorf("flag --%s: %w", name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const mask = "******"
+
+// Redacted returns the effective config keyed by yaml names, safe to log or
+// serve. Fields tagged secret:"true" are masked when set, and secret:"uri"
+// keeps the URI but replaces its password, and any query parameter that
+// holds a credential, with "xxxxx".
+func (c Config) Redacted() map[string]any {
+	out := map[string]any{"env": c.Env}
+
+	walk(reflect.ValueOf(&c).Elem(), "", func(name string, f reflect.StructField, v reflect.Value) {
+		var value any = v.Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		switch f.Tag.Get("secret") {
+		case "true":
+			if v.String() != "" {
+				value = mask
+			}
+		case "uri":
+			value = redactURI(v.String())
+		}
+
+		section, key, _ := strings.Cut(name, ".")
+		m, ok := out[section].(map[string]any)
+		if !ok {
+			m = map[string]any{}
+			out[section] = m
+		}
+		m[key] = value
+	})
+
+	return out
+}
+
+func redactURI(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		// an unparsable URI may still hold a password, so show none of it
+		if s == "" {
+			return ""
+		}
+		return mask
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, p := range params {
+			key, _, _ := strings.Cut(p, "=")
+			if name, err := url.QueryUnescape(key); err != nil || sensitiveParam(name) {
+				params[i] = key + "=xxxxx"
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.Redacted()
+}
+
+// sensitiveParam reports whether a URI query parameter carries a credential,
+// as libpq's password and sslpassword do.
+func sensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	return name == "password" || name == "sslpassword" ||
+		strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// validate returns every problem with cfg rather than stopping at the first.
+func (c Config) validate() []error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if !validPort(c.Server.Port) {
+		add("server.port: %q is not a port between 1 and 65535", c.Server.Port)
+	}
+	if c.Metrics.Port != "" {
+		if !validPort(c.Metrics.Port) {
+			add("metrics.port: %q is not a port between 1 and 65535", c.Metrics.Port)
+		} else if c.Metrics.Port == c.Server.Port {
+			add("metrics.port: %q must differ from server.port", c.Metrics.Port)
+		}
+	}
+
+	switch c.Server.TLS.Mode {
+	case "off":
+		if c.Server.TLS.ClientCAFile != "" {
+			add("server.tls.client_ca_file: needs server.tls.mode file or self-signed")
+		}
+	case "self-signed":
+	case "file":
+		if c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "" {
+			add("server.tls.cert_file, server.tls.key_file: are required when server.tls.mode is file")
+		}
+	default:
+		add("server.tls.mode: %q is not one of off, file, self-signed", c.Server.TLS.Mode)
+	}
+
+	switch c.Database.Driver {
+	case "", "postgres":
+		if c.Database.PostgresURI == "" {
+			add("database.postgres_uri: is required")
+		} else if u, err := url.Parse(c.Database.PostgresURI); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			add("database.postgres_uri: must be a postgres:// URI with a host")
+		}
+	case "ramsql":
+		if !c.Database.AutoMigrate {
+			add("database.auto_migrate: must be on with the ramsql driver, its database starts empty")
+		}
+		if c.FeatureFlag.Backend == "db" {
+			add("feature_flag.backend: db needs the postgres database driver")
+		}
+	default:
+		add("database.driver: %q is not one of postgres, ramsql", c.Database.Driver)
+	}
+
+	if c.Database.MaxOpenConns < 0 {
+		add("database.max_open_conns: %d must not be negative", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 {
+		add("database.max_idle_conns: %d must not be negative", c.Database.MaxIdleConns)
+	} else if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns: %d must not exceed database.max_open_conns %d", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	notNegative(&errs, "database.conn_max_lifetime", c.Database.ConnMaxLifetime)
+	notNegative(&errs, "database.conn_max_idle_time", c.Database.ConnMaxIdleTime)
+	positive(&errs, "database.query_timeout", c.Database.QueryTimeout)
+	notNegative(&errs, "database.statement_timeout", c.Database.StatementTimeout)
+	positive(&errs, "database.migrate_timeout", c.Database.MigrateTimeout)
+
+	if c.FeatureFlag.Environment == "" {
+		// flags gain environments rules at runtime through the admin API,
+		// and every one of them would deny against an empty name
+		add("feature_flag.environment: is required when ENV is not set, environments rules match against it")
+	}
+	switch c.FeatureFlag.Backend {
+	case "env", "db":
+	case "file":
+		if c.FeatureFlag.File == "" {
+			add("feature_flag.file: is required when feature_flag.backend is file")
+		}
+	default:
+		add("feature_flag.backend: %q is not one of env, file, db", c.FeatureFlag.Backend)
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+		if c.Storage.LocalRoot == "" {
+			add("storage.local_root: is required when storage.backend is local")
+		}
+	case "s3":
+		if c.Storage.S3Bucket == "" {
+			add("storage.s3_bucket: is required when storage.backend is s3")
+		}
+		if c.Storage.S3Region == "" {
+			add("storage.s3_region: is required when storage.backend is s3")
+		}
+	default:
+		add("storage.backend: %q is not one of local, s3", c.Storage.Backend)
+	}
+	if c.Storage.S3Endpoint != "" {
+		if u, err := url.Parse(c.Storage.S3Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add("storage.s3_endpoint: %q is not an absolute URL", c.Storage.S3Endpoint)
+		}
+	}
+	positive(&errs, "storage.presign_ttl", c.Storage.PresignTTL)
+
+	switch c.Scanner.Backend {
+	case "none":
+	case "clamd":
+		if c.Scanner.ClamdAddr == "" {
+			add("scanner.clamd_addr: is required when scanner.backend is clamd")
+		}
+	default:
+		add("scanner.backend: %q is not one of none, clamd", c.Scanner.Backend)
+	}
+	positive(&errs, "scanner.timeout", c.Scanner.Timeout)
+
+	if c.Tracing.Enabled {
+		if c.Tracing.Endpoint == "" {
+			add("tracing.otlp_endpoint: is required when tracing is enabled")
+		}
+		if c.Tracing.ServiceName == "" {
+			add("tracing.service_name: is required when tracing is enabled")
+		}
+	}
+
+	if c.AccessLog.MaxBody < 0 {
+		add("access_log.max_body: %d must not be negative", c.AccessLog.MaxBody)
+	}
+	if _, err := c.AccessLog.Rules(); err != nil {
+		add("access_log.redact: %s", err)
+	}
+
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		add("log.level: %q is not a log level", c.Log.Level)
+	}
+	if _, err := c.Log.PackageLevels(); err != nil {
+		add("log.packages: %s", err)
+	}
+	if _, err := c.Log.SampleRules(); err != nil {
+		add("log.sample: %s", err)
+	}
+
+	positive(&errs, "health.check_timeout", c.Health.CheckTimeout)
+	notNegative(&errs, "health.drain_delay", c.Health.DrainDelay)
+
+	if c.Auth.Secret == "" && !strings.EqualFold(c.Env, "LOCAL") {
+		add("auth.secret: is required outside LOCAL, every spender token is rejected without it")
+	}
+	if c.Debug.Enabled && c.Auth.AdminToken == "" {
+		add("debug.enabled: needs auth.admin_token, the debug endpoints are admin only")
+	}
+
+	return errs
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+func positive(errs *[]error, name string, d time.Duration) {
+	if d <= 0 {
+		*errs = append(*errs, fmt.Errorf("%s: %s must be greater than zero", name, d))
+	}
+}
+
+func notNegative(errs *[]error, name string, d time.Duration) {
+	if d < 0 {
+		*errs = append(*errs, fmt.Errorf("%s: %s must not be negative", name, d))
+	}
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/lib/pq"
+)
+
+// ConstraintError is a write the schema refused. Status is what it means for
+// the client: 409 Conflict when the row clashes with an existing one, 422
+// Unprocessable Entity when its values break a rule.
+type ConstraintError struct {
+	Status     int
+	Constraint string
+	Message    string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Message
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// Problem describes the violation for the client, with the constraint's name
+// as the code.
+func (e *ConstraintError) Problem() *problem.Error {
+	return &problem.Error{Status: e.Status, Code: e.Constraint, Detail: e.Message, Err: e}
+}
+
+// constraintMessages explains the constraints of the migrations to clients.
+var constraintMessages = map[string]string{
+	"spender_email_key":           "email is already used by another spender",
+	"transaction_spender_id_fkey": "spender does not exist",
+	"transaction_type_check":      "transaction_type must be income or expense",
+	"transaction_amount_check":    "amount must not be negative",
+}
+
+// ramsqlUnique matches the unique violations of ramsql, which only reports
+// the column as text: "constraint violation: email (TEXT unique) unicity".
+var ramsqlUnique = regexp.MustCompile(`^constraint violation: (\w+) \(.*\) unicity$`)
+
+// ramsqlConstraints names the constraint behind each unique column of the
+// ramsql schema, after the Postgres one it stands in for.
+var ramsqlConstraints = map[string]string{
+	"email": "spender_email_key",
+}
+
+// Violation builds the error Constraint reports for a violation of
+// constraint, for stores that enforce the schema's rules themselves.
+func Violation(status int, constraint string) *ConstraintError {
+	msg, ok := constraintMessages[constraint]
+	if !ok {
+		msg = "violates " + constraint
+	}
+	return &ConstraintError{Status: status, Constraint: constraint, Message: msg}
+}
+
+// Constraint reports whether err is a constraint violation and, if so,
+// describes it for the client. Other errors give nil, false.
+func Constraint(err error) (*ConstraintError, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	var ce *ConstraintError
+	if errors.As(err, &ce) {
+		return ce, true
+	}
+
+	if m := ramsqlUnique.FindStringSubmatch(err.Error()); m != nil {
+		constraint, ok := ramsqlConstraints[m[1]]
+		if !ok {
+			constraint = m[1]
+		}
+		v := Violation(http.StatusConflict, constraint)
+		v.Err = err
+		return v, true
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil, false
+	}
+
+	var status int
+	switch pqErr.Code.Name() {
+	case "unique_violation", "exclusion_violation":
+		status = http.StatusConflict
+	case "foreign_key_violation", "check_violation", "not_null_violation":
+		status = http.StatusUnprocessableEntity
+	default:
+		return nil, false
+	}
+
+	msg, ok := constraintMessages[pqErr.Constraint]
+	if !ok {
+		msg = pqErr.Message
+	}
+	return &ConstraintError{Status: status, Constraint: pqErr.Constraint, Message: msg, Err: err}, true
+}
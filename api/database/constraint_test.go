@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     *pq.Error
+		status  int
+		message string
+	}{
+		{"unique email", &pq.Error{Code: "23505", Constraint: "spender_email_key"}, http.StatusConflict, "email is already used by another spender"},
+		{"missing spender", &pq.Error{Code: "23503", Constraint: "transaction_spender_id_fkey"}, http.StatusUnprocessableEntity, "spender does not exist"},
+		{"bad type", &pq.Error{Code: "23514", Constraint: "transaction_type_check"}, http.StatusUnprocessableEntity, "transaction_type must be income or expense"},
+		{"unknown constraint keeps the postgres message", &pq.Error{Code: "23502", Message: `null value in column "name"`}, http.StatusUnprocessableEntity, `null value in column "name"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce, ok := Constraint(fmt.Errorf("insert: %w", tt.err))
+
+			assert.True(t, ok)
+			assert.Equal(t, tt.status, ce.Status)
+			assert.Equal(t, tt.err.Constraint, ce.Constraint)
+			assert.Equal(t, tt.message, ce.Message)
+			assert.ErrorIs(t, ce, tt.err)
+		})
+	}
+
+	t.Run("passes a store's own violation through", func(t *testing.T) {
+		v := Violation(http.StatusConflict, "spender_email_key")
+
+		ce, ok := Constraint(fmt.Errorf("create: %w", v))
+
+		assert.True(t, ok)
+		assert.Same(t, v, ce)
+		assert.Equal(t, "email is already used by another spender", ce.Message)
+	})
+
+	t.Run("reads ramsql unique violations", func(t *testing.T) {
+		ce, ok := Constraint(errors.New("constraint violation: email (TEXT unique) unicity"))
+
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusConflict, ce.Status)
+		assert.Equal(t, "spender_email_key", ce.Constraint)
+		assert.Equal(t, "email is already used by another spender", ce.Message)
+	})
+
+	t.Run("describes itself as a problem", func(t *testing.T) {
+		p := Violation(http.StatusConflict, "spender_email_key").Problem()
+
+		assert.Equal(t, http.StatusConflict, p.Status)
+		assert.Equal(t, "spender_email_key", p.Code)
+		assert.Equal(t, "email is already used by another spender", p.Detail)
+	})
+
+	t.Run("ignores other errors", func(t *testing.T) {
+		_, ok := Constraint(&pq.Error{Code: "42P01"})
+		assert.False(t, ok)
+
+		_, ok = Constraint(assert.AnError)
+		assert.False(t, ok)
+	})
+}
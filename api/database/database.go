@@ -0,0 +1,135 @@
+// Package database opens the Postgres pool, or the embedded ramsql database
+// for local development, and bounds the time handlers spend on queries.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/XSAM/otelsql"
+	"github.com/labstack/echo/v4"
+	_ "github.com/lib/pq"
+	_ "github.com/proullon/ramsql/driver"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// DefaultTimeout is the query deadline used when no Deadline middleware ran.
+const DefaultTimeout = 5 * time.Second
+
+const timeoutKey = "db-timeout"
+
+// ramsqlName names the in-process database. ramsql keeps one per name for
+// the life of the process, so every connection of the pool shares it.
+const ramsqlName = "hongjot"
+
+// Open connects to Postgres, or ramsql when cfg.Driver says so, and applies
+// the pool settings from cfg. Every query and exec becomes an OpenTelemetry
+// span, a child of the span in its context, recording the statement.
+func Open(cfg config.Database) (*sql.DB, error) {
+	if cfg.Driver == "ramsql" {
+		return open("ramsql", ramsqlName, cfg)
+	}
+
+	dsn, err := dataSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return open("postgres", dsn, cfg)
+}
+
+func open(driverName, dsn string, cfg config.Database) (*sql.DB, error) {
+	system := semconv.DBSystemPostgreSQL
+	if driverName == "ramsql" {
+		system = semconv.DBSystemOtherSQL
+	}
+	db, err := otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// dataSource adds statement_timeout to the URI. lib/pq sends parameters it
+// does not know to the server, so Postgres cancels statements running longer.
+func dataSource(cfg config.Database) (string, error) {
+	if cfg.StatementTimeout <= 0 {
+		return cfg.PostgresURI, nil
+	}
+
+	u, err := url.Parse(cfg.PostgresURI)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Deadline sets the query timeout handlers get from Context.
+func Deadline(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(timeoutKey, timeout)
+			return next(c)
+		}
+	}
+}
+
+// Context returns the request context bounded by the query timeout. Handlers
+// use it for their database calls and must call cancel when done.
+func Context(c echo.Context) (context.Context, context.CancelFunc) {
+	timeout, ok := c.Get(timeoutKey).(time.Duration)
+	if !ok || timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(c.Request().Context(), timeout)
+}
+
+// PoolStats is sql.DBStats with snake_case names for the admin API.
+type PoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// Stats is the admin endpoint reporting the connection pool.
+func Stats(db *sql.DB) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		s := db.Stats()
+		return c.JSON(http.StatusOK, PoolStats{
+			MaxOpenConnections: s.MaxOpenConnections,
+			OpenConnections:    s.OpenConnections,
+			InUse:              s.InUse,
+			Idle:               s.Idle,
+			WaitCount:          s.WaitCount,
+			WaitDuration:       s.WaitDuration.String(),
+			MaxIdleClosed:      s.MaxIdleClosed,
+			MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  s.MaxLifetimeClosed,
+		})
+	}
+}
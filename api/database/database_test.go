@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestDataSource(t *testing.T) {
+	t.Run("keeps the uri without a statement timeout", func(t *testing.T) {
+		dsn, err := dataSource(config.Database{PostgresURI: "postgres://u:p@db:5432/app?sslmode=disable"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "postgres://u:p@db:5432/app?sslmode=disable", dsn)
+	})
+
+	t.Run("adds statement_timeout in milliseconds", func(t *testing.T) {
+		dsn, err := dataSource(config.Database{
+			PostgresURI:      "postgres://u:p@db:5432/app?sslmode=disable",
+			StatementTimeout: 2 * time.Second,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "postgres://u:p@db:5432/app?sslmode=disable&statement_timeout=2000", dsn)
+	})
+}
+
+func TestOpenTracesQueries(t *testing.T) {
+	before := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(before)
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	otel.SetTracerProvider(tp)
+
+	_, mock, err := sqlmock.NewWithDSN("traced", sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	mock.ExpectQuery("SELECT name FROM spender WHERE id = $1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("HongJot"))
+
+	db, err := open("sqlmock", "traced", config.Database{MaxOpenConns: 1})
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	var name string
+	err = db.QueryRowContext(ctx, "SELECT name FROM spender WHERE id = $1", 1).Scan(&name)
+	parent.End()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "HongJot", name)
+	var query sdktrace.ReadOnlySpan
+	for _, s := range spans.Ended() {
+		if s.Name() == "sql.conn.query" {
+			query = s
+		}
+	}
+	if assert.NotNil(t, query) {
+		assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Contains(t, query.Attributes(), semconv.DBStatement("SELECT name FROM spender WHERE id = $1"))
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Run("uses the default timeout without the middleware", func(t *testing.T) {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+		ctx, cancel := Context(c)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(DefaultTimeout), deadline, time.Second)
+	})
+
+	t.Run("uses the timeout set by Deadline", func(t *testing.T) {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+		var deadline time.Time
+		h := Deadline(100 * time.Millisecond)(func(c echo.Context) error {
+			ctx, cancel := Context(c)
+			defer cancel()
+			deadline, _ = ctx.Deadline()
+			return nil
+		})
+
+		assert.NoError(t, h(c))
+		assert.WithinDuration(t, time.Now().Add(100*time.Millisecond), deadline, 100*time.Millisecond)
+	})
+}
+
+func TestStats(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	err := Stats(db)(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got PoolStats
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, 7, got.MaxOpenConnections)
+	assert.Equal(t, "0s", got.WaitDuration)
+}
@@ -0,0 +1,64 @@
+// Package debug holds diagnostic and demo endpoints. They are only mounted
+// when debug.enabled is set, and only behind admin auth.
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// Register mounts the endpoints on g:
+//
+//	/pprof/        profile index, /pprof/<name> for heap, goroutine, etc.
+//	/vars          expvar as JSON
+//	/goroutines    full stack of every goroutine as text
+//	/slow          a request that takes ?for=10s, to try graceful shutdown
+func Register(g *echo.Group) {
+	g.GET("/pprof/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	g.GET("/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.POST("/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	// pprof.Index finds the profile by a /debug/pprof/ prefix we don't have
+	g.GET("/pprof/:name", func(c echo.Context) error {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+	g.GET("/vars", echo.WrapHandler(expvar.Handler()))
+	g.GET("/goroutines", Goroutines)
+	g.GET("/slow", Slow)
+}
+
+// Goroutines dumps the stack of every goroutine, like a SIGQUIT without
+// killing the process.
+func Goroutines(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return runtimepprof.Lookup("goroutine").WriteTo(c.Response(), 2)
+}
+
+// Slow is for Demo purpose to simulate slow endpoint. It gives up early if
+// the client goes away.
+func Slow(c echo.Context) error {
+	d := 10 * time.Second
+	if s := c.QueryParam("for"); s != "" {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil || d < 0 {
+			return problem.Validation("invalid_duration", "for must be a duration like 10s")
+		}
+	}
+
+	select {
+	case <-time.After(d):
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok", "slept": d.String()})
+	case <-c.Request().Context().Done():
+		return c.Request().Context().Err()
+	}
+}
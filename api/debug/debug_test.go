@@ -0,0 +1,57 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler
+	Register(e.Group("/admin/debug"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("should serve the pprof index and named profiles", func(t *testing.T) {
+		rec := get("/admin/debug/pprof/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "heap")
+
+		rec = get("/admin/debug/pprof/heap?debug=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "heap profile")
+	})
+
+	t.Run("should serve expvar", func(t *testing.T) {
+		rec := get("/admin/debug/vars")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"memstats"`)
+	})
+
+	t.Run("should dump every goroutine", func(t *testing.T) {
+		rec := get("/admin/debug/goroutines")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "goroutine ")
+		assert.Contains(t, rec.Body.String(), "TestRegister")
+	})
+
+	t.Run("should sleep for the given duration", func(t *testing.T) {
+		rec := get("/admin/debug/slow?for=1ms")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"status": "ok", "slept": "1ms"}`, rec.Body.String())
+
+		rec = get("/admin/debug/slow?for=soon")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -1,44 +1,155 @@
 package eslip
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
-	"mime/multipart"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/constanst"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/google/uuid"
+	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
-func Upload(c echo.Context) error {
+type Slip struct {
+	ID          int64
+	SpenderID   int64
+	ObjectKey   string
+	Filename    string
+	ContentType string
+	Status      string
+}
+
+const (
+	statusPending = "pending"
+	statusStored  = "stored"
+)
+
+type PresignRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type PresignResponse struct {
+	ID        int64             `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type handler struct {
+	db         *sql.DB
+	store      Storage
+	scanner    Scanner
+	presignTTL time.Duration
+}
+
+func New(db *sql.DB, store Storage, scanner Scanner, presignTTL time.Duration) *handler {
+	return &handler{db, store, scanner, presignTTL}
+}
+
+const (
+	cStmt = `INSERT INTO slip (spender_id, object_key, filename, content_type, size, sanitized) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+	pStmt = `INSERT INTO slip (spender_id, object_key, filename, content_type, status) VALUES ($1, $2, $3, $4, 'pending') RETURNING id;`
+	gStmt = `SELECT id, spender_id, object_key, filename, content_type, status FROM slip WHERE id=$1`
+	uStmt = `UPDATE slip SET status='stored', object_key=$1, size=$2, content_type=$3, sanitized=$4 WHERE id=$5 AND status='pending'`
+	qStmt = `UPDATE slip SET status='quarantined', object_key=$1 WHERE id=$2 AND status='pending'`
+	aStmt = `INSERT INTO slip_audit (spender_id, slip_id, object_key, event, detail) VALUES ($1, $2, $3, $4, $5)`
+)
+
+const quarantinePrefix = "quarantine/"
+
+var errInfected = errors.New("file rejected by malware scan")
+
+func objectKey(spenderID int64, filename string) string {
+	return fmt.Sprintf("slips/%d/%s%s", spenderID, uuid.NewString(), path.Ext(filename))
+}
+
+// uploadKey is where a presigned upload lands. Complete moves the checked
+// object to an objectKey, so the presigned URL, which stays valid until it
+// expires, can never overwrite what is served.
+func uploadKey(spenderID int64, filename string) string {
+	return "incoming/" + objectKey(spenderID, filename)
+}
+
+func location(id int64) string {
+	return fmt.Sprintf("/api/v1/slips/%d", id)
+}
+
+func (h handler) Upload(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, ok := auth.SpenderID(c)
+	if !ok {
+		return problem.Unauthorized("unauthorized", "unauthorized")
+	}
+
 	form, err := c.MultipartForm()
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": "Failed to parse form",
-			"error":   err.Error(),
-		})
+		return problem.Validation("invalid_form", "failed to parse form").Wrap(err)
 	}
 	images := form.File["images"]
 	var locations []string
 	for _, image := range images {
-		fmt.Printf("Uploading file: %+v\n", image.Filename)
+		logger.Info("uploading file", zap.String("filename", image.Filename))
 		src, err := image.Open()
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"message": "Failed to parse form",
-				"error":   err.Error(),
-			})
+			return problem.Validation("invalid_form", "failed to parse form").Wrap(err)
 		}
 		defer src.Close()
 
-		// upload to AWS S3 bucket
-		loc, err := UploadToS3(c, image.Filename, src)
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return problem.Validation("invalid_form", "failed to parse form").Wrap(err)
+		}
+
+		key := objectKey(spenderID, image.Filename)
+		if err := h.scan(c, spenderID, 0, key, data); err != nil {
+			return h.scanFailed(c, err)
+		}
+
+		obj, err := prepare(data)
+		if err != nil {
+			logger.Error("sanitize error", zap.String("filename", image.Filename), zap.Error(err))
+			metrics.Upload(metrics.UploadRejected)
+			return problem.New(http.StatusUnprocessableEntity, "unreadable_file", "failed to read image").Wrap(err)
+		}
+
+		if err := h.store.Put(ctx, key, bytes.NewReader(obj.data)); err != nil {
+			logger.Error("store error", zap.Error(err))
+			metrics.Upload(metrics.UploadFailed)
+			return problem.Internal(err)
+		}
+
+		var id int64
+		err = h.queryRow(c, cStmt, []any{spenderID, key, image.Filename, obj.contentType, len(obj.data), obj.sanitized}, &id)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"message": "Failed to upload image",
-				"error":   err.Error(),
-			})
+			logger.Error(constanst.QueryError, zap.Error(err))
+			metrics.DBError()
+			metrics.Upload(metrics.UploadFailed)
+			return problem.Internal(err)
 		}
-		locations = append(locations, loc)
+		metrics.Upload(metrics.UploadStored)
+		locations = append(locations, location(id))
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -47,26 +158,412 @@ func Upload(c echo.Context) error {
 	})
 }
 
-func UploadToS3(c echo.Context, filename string, src multipart.File) (string, error) {
-	// Assume that the file is uploaded to S3 bucket successfully
-	return "location/on/s3/bucket/" + filename, nil
-	// Create an AWS session
-	// sess, err := session.NewSession(&aws.Config{
-	// 	Region: aws.String(os.Getenv("AWS_REGION")),
-	// })
-	// if err != nil {
-	// 	return "", err
-	// }
-
-	// Upload the file to S3
-	// result, err = s3.New(sess).PutObject(&s3.PutObjectInput{
-	// 	Bucket: aws.String(os.Getenv("AWS_BUCKET")),
-	// 	Key:    aws.String(filename),
-	// 	Body:   src,
-	// })
-	// if err != nil {
-	// 	return "", err
-	// }
-
-	// return result.Location, nil
+// Presign reserves a slip and returns a short-lived URL the client uploads
+// the file to directly, bypassing this server. The slip stays pending until
+// Complete is called.
+func (h handler) Presign(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, ok := auth.SpenderID(c)
+	if !ok {
+		return problem.Unauthorized("unauthorized", "unauthorized")
+	}
+
+	var req PresignRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(constanst.BadRequestBody, zap.Error(err))
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
+	}
+	if req.Filename == "" || req.ContentType == "" || req.Size <= 0 {
+		return problem.Validation("missing_fields", "filename, content_type and size are required")
+	}
+	if req.Size > maxUploadSize {
+		return tooLarge()
+	}
+
+	key := uploadKey(spenderID, req.Filename)
+	url, err := h.store.Presign(ctx, key, req.ContentType, req.Size, h.presignTTL)
+	if err != nil {
+		logger.Error("presign error", zap.Error(err))
+		return problem.Internal(err)
+	}
+
+	var id int64
+	err = h.queryRow(c, pStmt, []any{spenderID, key, req.Filename, req.ContentType}, &id)
+	if err != nil {
+		logger.Error(constanst.QueryError, zap.Error(err))
+		metrics.DBError()
+		return problem.Internal(err)
+	}
+
+	logger.Info("presign successfully", zap.Int64("id", id))
+	return c.JSON(http.StatusCreated, PresignResponse{
+		ID:     id,
+		Method: http.MethodPut,
+		URL:    url,
+		Headers: map[string]string{
+			echo.HeaderContentType:   req.ContentType,
+			echo.HeaderContentLength: strconv.FormatInt(req.Size, 10),
+		},
+		ExpiresAt: time.Now().Add(h.presignTTL).UTC(),
+	})
+}
+
+// Complete is called by the client once its presigned upload has finished.
+// It checks the object really landed in storage and marks the slip stored.
+// Completing a stored slip again answers as before; any other status that
+// is not pending is a conflict.
+func (h handler) Complete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	s, err := h.slip(c)
+	if err != nil {
+		return err
+	}
+
+	if s.Status == statusStored {
+		info, err := h.store.Stat(ctx, s.ObjectKey)
+		if err != nil {
+			logger.Error("store error", zap.Error(err))
+			return problem.Internal(err)
+		}
+		return c.JSON(http.StatusOK, completed(s.ID, info.Size))
+	}
+	// a quarantined slip must never be scanned again: a retry could come
+	// back clean and get the file served
+	if s.Status != statusPending {
+		return problem.Conflict("slip_not_pending", "slip is "+s.Status+" and cannot be completed")
+	}
+
+	f, info, err := h.store.Open(ctx, s.ObjectKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return problem.Conflict("upload_missing", "upload not found in storage")
+	}
+	if err != nil {
+		logger.Error("store error", zap.Error(err))
+		return problem.Internal(err)
+	}
+	// read one byte past the limit so nothing oversize is silently cut
+	// short and stored unscanned
+	var data []byte
+	if info.Size <= maxUploadSize {
+		data, err = io.ReadAll(io.LimitReader(f, maxUploadSize+1))
+	}
+	f.Close()
+	if err != nil {
+		logger.Error("store error", zap.Error(err))
+		return problem.Internal(err)
+	}
+	if info.Size > maxUploadSize || len(data) > maxUploadSize {
+		logger.Warn("presigned upload is too large", zap.Int64("id", s.ID), zap.Int64("size", info.Size))
+		if err := h.store.Delete(ctx, s.ObjectKey); err != nil {
+			logger.Error("store error", zap.Error(err))
+		}
+		metrics.Upload(metrics.UploadRejected)
+		return tooLarge()
+	}
+
+	// the client uploaded straight to storage, so the scanning and metadata
+	// stripping Upload does inline have to happen here before it is served
+	if err := h.scan(c, s.SpenderID, s.ID, s.ObjectKey, data); err != nil {
+		if errors.Is(err, errInfected) {
+			if err := h.store.Delete(ctx, s.ObjectKey); err != nil {
+				logger.Error("store error", zap.Error(err))
+			}
+			if err := h.exec(c, qStmt, quarantinePrefix+s.ObjectKey, s.ID); err != nil {
+				logger.Error("update error", zap.Error(err))
+				metrics.DBError()
+			}
+		}
+		return h.scanFailed(c, err)
+	}
+
+	obj, err := prepare(data)
+	if err != nil {
+		logger.Error("sanitize error", zap.Int64("id", s.ID), zap.Error(err))
+		metrics.Upload(metrics.UploadRejected)
+		return problem.New(http.StatusUnprocessableEntity, "unreadable_file", "failed to read image").Wrap(err)
+	}
+	// what was checked is copied out of the presigned key's reach
+	key := objectKey(s.SpenderID, s.Filename)
+	if err := h.store.Put(ctx, key, bytes.NewReader(obj.data)); err != nil {
+		logger.Error("store error", zap.Error(err))
+		metrics.Upload(metrics.UploadFailed)
+		return problem.Internal(err)
+	}
+
+	size := int64(len(obj.data))
+	if err := h.exec(c, uStmt, key, size, obj.contentType, obj.sanitized, s.ID); err != nil {
+		logger.Error("update error", zap.Error(err))
+		metrics.DBError()
+		metrics.Upload(metrics.UploadFailed)
+		return problem.Internal(err)
+	}
+	if err := h.store.Delete(ctx, s.ObjectKey); err != nil {
+		logger.Warn("failed to delete the presigned upload", zap.String("key", s.ObjectKey), zap.Error(err))
+	}
+	logger.Info("upload completed", zap.Int64("id", s.ID), zap.Int64("size", size), zap.Bool("sanitized", obj.sanitized))
+	metrics.Upload(metrics.UploadStored)
+
+	return c.JSON(http.StatusOK, completed(s.ID, size))
+}
+
+func tooLarge() error {
+	return problem.New(http.StatusRequestEntityTooLarge, "upload_too_large", fmt.Sprintf("upload is larger than %d bytes", maxUploadSize))
 }
+
+func completed(id, size int64) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       id,
+		"location": location(id),
+		"size":     size,
+	}
+}
+
+// scan runs the malware scanner over data. Infected data is written under the
+// quarantine prefix instead of key, an audit entry is recorded and
+// errInfected is returned. slipID is 0 when no slip row exists yet.
+func (h handler) scan(c echo.Context, spenderID, slipID int64, key string, data []byte) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	verdict, err := h.scanner.Scan(ctx, bytes.NewReader(data))
+	if err != nil {
+		logger.Error("scan error", zap.String("key", key), zap.Error(err))
+		return err
+	}
+	if !verdict.Infected {
+		return nil
+	}
+
+	logger.Warn("infected upload quarantined",
+		zap.String("key", key),
+		zap.Int64("spender_id", spenderID),
+		zap.String("signature", verdict.Signature))
+
+	if err := h.store.Put(ctx, quarantinePrefix+key, bytes.NewReader(data)); err != nil {
+		logger.Error("quarantine error", zap.Error(err))
+	}
+
+	var slip sql.NullInt64
+	if slipID != 0 {
+		slip = sql.NullInt64{Int64: slipID, Valid: true}
+	}
+	if err := h.exec(c, aStmt, spenderID, slip, quarantinePrefix+key, "quarantined", verdict.Signature); err != nil {
+		logger.Error(constanst.QueryError, zap.Error(err))
+		metrics.DBError()
+	}
+
+	return fmt.Errorf("%w: %s", errInfected, verdict.Signature)
+}
+
+// scanFailed is 422 for infected files and 503 when the scanner itself
+// could not give a verdict, so nothing unscanned is ever stored.
+func (h handler) scanFailed(c echo.Context, err error) error {
+	if errors.Is(err, errInfected) {
+		metrics.Upload(metrics.UploadInfected)
+		return problem.New(http.StatusUnprocessableEntity, "infected", err.Error()).Wrap(err)
+	}
+	metrics.Upload(metrics.UploadScanFailed)
+	return problem.New(http.StatusServiceUnavailable, "scanner_unavailable", "malware scanner is unavailable").Wrap(err)
+}
+
+type object struct {
+	data        []byte
+	contentType string
+	sanitized   bool
+}
+
+// prepare turns uploaded bytes into what gets stored: the content type is
+// detected from the bytes rather than trusted from the client, and image
+// metadata is stripped.
+func prepare(data []byte) (object, error) {
+	contentType := detectContentType(data)
+	clean, sanitized, err := sanitize(contentType, data)
+	if err != nil {
+		return object{}, err
+	}
+	return object{data: clean, contentType: contentType, sanitized: sanitized}, nil
+}
+
+// exec and queryRow bound each statement by the request's query timeout
+// rather than the whole request, which may spend longer on storage.
+func (h handler) exec(c echo.Context, query string, args ...any) error {
+	ctx, cancel := database.Context(c)
+	defer cancel()
+
+	_, err := h.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (h handler) queryRow(c echo.Context, query string, args []any, dest ...any) error {
+	ctx, cancel := database.Context(c)
+	defer cancel()
+
+	return h.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+}
+
+// slip loads the slip and checks it belongs to the authenticated spender.
+func (h handler) slip(c echo.Context) (Slip, error) {
+	logger := mlog.L(c)
+
+	spenderID, ok := auth.SpenderID(c)
+	if !ok {
+		return Slip{}, problem.Unauthorized("unauthorized", "unauthorized")
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(constanst.NonIntError)
+		return Slip{}, problem.Validation("invalid_id", "id must be an integer")
+	}
+
+	var s Slip
+	err = h.queryRow(c, gStmt, []any{id}, &s.ID, &s.SpenderID, &s.ObjectKey, &s.Filename, &s.ContentType, &s.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Slip{}, problem.NotFound("slip_not_found", "slip not found")
+	}
+	if err != nil {
+		logger.Error(constanst.QueryError, zap.Error(err))
+		metrics.DBError()
+		return Slip{}, problem.Internal(err)
+	}
+
+	if s.SpenderID != spenderID {
+		logger.Warn("slip access denied", zap.Int64("id", id), zap.Int64("spender_id", spenderID))
+		return Slip{}, problem.Forbidden("access_denied", "access denied")
+	}
+
+	return s, nil
+}
+
+func (h handler) Download(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	s, err := h.slip(c)
+	if err != nil {
+		return err
+	}
+	if s.Status != statusStored {
+		return problem.NotFound("slip_incomplete", "slip upload is not complete")
+	}
+
+	f, info, err := h.store.Open(ctx, s.ObjectKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return problem.NotFound("slip_not_found", "slip not found")
+	}
+	if err != nil {
+		logger.Error("store error", zap.Error(err))
+		return problem.Internal(err)
+	}
+	defer f.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, s.ContentType)
+	res.Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("%s; filename=%q", disposition(s.ContentType), s.Filename))
+	res.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(res, c.Request(), s.Filename, info.ModTime, f)
+	return nil
+}
+
+// disposition is inline only for types a browser displays without running
+// them. Anything else, an uploaded HTML page or SVG included, would run as a
+// page of the API origin, so it is downloaded instead.
+func disposition(contentType string) string {
+	if contentType == "application/pdf" || (strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "image/svg")) {
+		return "inline"
+	}
+	return "attachment"
+}
+
+func (h handler) Thumbnail(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	width := defaultThumbnailWidth
+	if w := c.QueryParam("w"); w != "" {
+		v, err := strconv.Atoi(w)
+		if err != nil || v < minThumbnailWidth || v > maxThumbnailWidth {
+			return problem.Validation("invalid_width", fmt.Sprintf("w must be between %d and %d", minThumbnailWidth, maxThumbnailWidth))
+		}
+		width = v
+	}
+
+	s, err := h.slip(c)
+	if err != nil {
+		return err
+	}
+
+	if s.Status != statusStored {
+		return problem.NotFound("slip_incomplete", "slip upload is not complete")
+	}
+	if !strings.HasPrefix(s.ContentType, "image/") {
+		return problem.New(http.StatusUnsupportedMediaType, "not_an_image", "thumbnail is only available for images")
+	}
+
+	key := fmt.Sprintf("thumbnails/%d/%d.jpg", s.ID, width)
+	f, info, err := h.store.Open(ctx, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		f, info, err = h.generateThumbnail(c, s, key, width)
+	}
+	if errors.Is(err, image.ErrFormat) {
+		return problem.New(http.StatusUnsupportedMediaType, "not_an_image", "thumbnail is only available for images")
+	}
+	if errors.Is(err, errImageTooLarge) {
+		return problem.New(http.StatusUnprocessableEntity, "image_too_large", "image is too large for a thumbnail")
+	}
+	if errors.Is(err, ErrObjectNotFound) {
+		return problem.NotFound("slip_not_found", "slip not found")
+	}
+	if err != nil {
+		logger.Error("thumbnail error", zap.Error(err))
+		return problem.Internal(err)
+	}
+	defer f.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "image/jpeg")
+	res.Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	res.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(res, c.Request(), path.Base(key), info.ModTime, f)
+	return nil
+}
+
+// generateThumbnail renders a thumbnail from the original object and caches
+// it under key so later requests are served straight from storage.
+func (h handler) generateThumbnail(c echo.Context, s Slip, key string, width int) (io.ReadSeekCloser, ObjectInfo, error) {
+	ctx := c.Request().Context()
+
+	src, _, err := h.store.Open(ctx, s.ObjectKey)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	defer src.Close()
+
+	img, err := decodeBounded(src)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail(img, width), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	if err := h.store.Put(ctx, key, bytes.NewReader(buf.Bytes())); err != nil {
+		mlog.L(c).Warn("failed to cache thumbnail", zap.String("key", key), zap.Error(err))
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	return nopCloser{r}, ObjectInfo{Size: r.Size(), ModTime: time.Now()}, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
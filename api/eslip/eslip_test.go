@@ -1,32 +1,582 @@
 package eslip
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestUploadToS3(t *testing.T) {
-	t.Run("should able to upload image to S3 bucket", func(t *testing.T) {
-		f, _ := os.CreateTemp("", "eslip1.jpg")
-		defer f.Close()
-		defer os.Remove(f.Name())
+func newJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func slipRows(spenderID int64, contentType string) *sqlmock.Rows {
+	return slipRowsWithStatus(spenderID, contentType, statusStored)
+}
+
+func slipRowsWithStatus(spenderID int64, contentType, status string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "spender_id", "object_key", "filename", "content_type", "status"}).
+		AddRow(1, spenderID, "slips/1/a.jpg", "eslip1.jpg", contentType, status)
+}
+
+// capture is a sqlmock argument matching any string and keeping it in dst.
+type capture struct{ dst *string }
+
+func (c capture) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*c.dst = s
+	return ok
+}
+
+func TestUpload(t *testing.T) {
+	t.Run("should store image and record slip for the spender", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		part, _ := w.CreateFormFile("images", "eslip1.jpg")
+		part.Write(newJPEG(t, 4, 4))
+		w.Close()
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(cStmt).
+			WithArgs(int64(1), sqlmock.AnyArg(), "eslip1.jpg", "image/jpeg", sqlmock.AnyArg(), true).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+		h := New(db, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute)
+		err := h.Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "/api/v1/slips/9")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should reject unauthenticated upload", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(nil, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute)
+		err := h.Upload(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, problem.From(err).Status)
+	})
+}
+
+type stubScanner struct {
+	verdict Verdict
+	err     error
+}
+
+func (s stubScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	return s.verdict, s.err
+}
+
+func TestUploadScanning(t *testing.T) {
+	setup := func(t *testing.T, store Storage, scanner Scanner, mock func(sqlmock.Sqlmock)) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		part, _ := w.CreateFormFile("images", "eslip1.jpg")
+		part.Write(newJPEG(t, 4, 4))
+		w.Close()
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.SetSpenderID(c, 1)
+
+		db, m, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock(m)
+
+		if err := New(db, store, scanner, time.Minute).Upload(c); err != nil {
+			problem.Handler(err, c)
+		}
+		assert.NoError(t, m.ExpectationsWereMet())
+		return rec
+	}
+
+	t.Run("should quarantine an infected file and audit it", func(t *testing.T) {
+		root := t.TempDir()
+		scanner := stubScanner{verdict: Verdict{Infected: true, Signature: "Eicar-Signature"}}
+
+		rec := setup(t, NewLocalStorage(root, "secret"), scanner, func(m sqlmock.Sqlmock) {
+			m.ExpectExec(aStmt).
+				WithArgs(int64(1), nil, sqlmock.AnyArg(), "quarantined", "Eicar-Signature").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Eicar-Signature")
+		quarantined, _ := filepath.Glob(filepath.Join(root, "quarantine", "slips", "1", "*.jpg"))
+		assert.Len(t, quarantined, 1)
+		stored, _ := filepath.Glob(filepath.Join(root, "slips", "1", "*.jpg"))
+		assert.Empty(t, stored)
+	})
+
+	t.Run("should refuse to store when the scanner fails", func(t *testing.T) {
+		rec := setup(t, NewLocalStorage(t.TempDir(), "secret"), stubScanner{err: assert.AnError}, func(m sqlmock.Sqlmock) {})
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
+
+func TestDownload(t *testing.T) {
+	serve := func(t *testing.T, spenderID int64, header http.Header, contentType, content string) (*httptest.ResponseRecorder, error) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", strings.NewReader(content))
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, spenderID)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRows(1, contentType))
+
+		return rec, New(db, store, NopScanner{}, time.Minute).Download(c)
+	}
+	setup := func(t *testing.T, spenderID int64, header http.Header) (*httptest.ResponseRecorder, error) {
+		return serve(t, spenderID, header, "image/jpeg", "0123456789")
+	}
+
+	t.Run("should stream the original with its content type", func(t *testing.T) {
+		rec, err := setup(t, 1, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+		assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentDisposition), "inline;"))
+		assert.Equal(t, "0123456789", rec.Body.String())
+	})
+
+	t.Run("should download an uploaded html page rather than render it", func(t *testing.T) {
+		page := "<html><script>alert(document.cookie)</script></html>"
+		rec, err := serve(t, 1, nil, detectContentType([]byte(page)), page)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+		assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentDisposition), "attachment;"))
+	})
+
+	t.Run("should serve a byte range", func(t *testing.T) {
+		rec, err := setup(t, 1, http.Header{"Range": {"bytes=2-5"}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, "2345", rec.Body.String())
+	})
+
+	t.Run("should deny access to another spender's slip", func(t *testing.T) {
+		_, err := setup(t, 2, nil)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, problem.From(err).Status)
+	})
+
+	t.Run("should return not found for unknown slip", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		err := New(db, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Download(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, problem.From(err).Status)
+	})
+
+	t.Run("should return bad request for non integer id", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("non-int")
+		auth.SetSpenderID(c, 1)
+
+		err := New(nil, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Download(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
+	})
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Run("should resize and cache a jpeg thumbnail", func(t *testing.T) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", bytes.NewReader(newJPEG(t, 200, 100)))
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?w=50", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRows(1, "image/jpeg"))
+
+		err := New(db, store, NopScanner{}, time.Minute).Thumbnail(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get(echo.HeaderContentType))
+		img, err := jpeg.Decode(rec.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 50, 25), img.Bounds())
+
+		cached, _, err := store.Open(context.Background(), "thumbnails/1/50.jpg")
+		assert.NoError(t, err)
+		cached.Close()
+	})
+
+	t.Run("should reject non image slips", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRows(1, "application/pdf"))
+
+		err := New(db, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Thumbnail(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, problem.From(err).Status)
+	})
+
+	t.Run("should refuse to decode a huge image", func(t *testing.T) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", bytes.NewReader(pngHeader(60000, 60000)))
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRows(1, "image/png"))
+
+		err := New(db, store, NopScanner{}, time.Minute).Thumbnail(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.From(err).Status)
+	})
+
+	t.Run("should reject out of range width", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?w=5000", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := New(nil, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Thumbnail(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
+	})
+}
 
+func TestPresign(t *testing.T) {
+	t.Run("should reserve a pending slip and return a signed url", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"filename": "big.pdf", "content_type": "application/pdf", "size": 9}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(pStmt).
+			WithArgs(int64(1), sqlmock.AnyArg(), "big.pdf", "application/pdf").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+		err := New(db, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Presign(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":3`)
+		assert.Contains(t, rec.Body.String(), LocalUploadPath+"/incoming/slips/1/")
+		assert.Contains(t, rec.Body.String(), "signature=")
+		assert.Contains(t, rec.Body.String(), `"Content-Length":"9"`)
+	})
+
+	t.Run("should refuse to presign more than the upload limit", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		body := fmt.Sprintf(`{"filename": "big.pdf", "content_type": "application/pdf", "size": %d}`, maxUploadSize+1)
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.SetSpenderID(c, 1)
+
+		err := New(nil, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Presign(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, problem.From(err).Status)
+	})
+
+	t.Run("should require filename and content type", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"filename": "big.pdf"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.SetSpenderID(c, 1)
+
+		err := New(nil, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Presign(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
+	})
+}
+
+func TestComplete(t *testing.T) {
+	setup := func(t *testing.T, store Storage, mock func(sqlmock.Sqlmock)) (*httptest.ResponseRecorder, error) {
 		e := echo.New()
 		defer e.Close()
 
 		req := httptest.NewRequest(http.MethodPost, "/", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, m, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock(m)
+
+		err := New(db, store, NopScanner{}, time.Minute).Complete(c)
+		assert.NoError(t, m.ExpectationsWereMet())
+		return rec, err
+	}
 
-		loc, err := UploadToS3(c, "eslip1.jpg", f)
+	t.Run("should mark a pending slip stored once the object exists", func(t *testing.T) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", strings.NewReader("12345"))
 
+		var key string
+		rec, err := setup(t, store, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "pending"))
+			m.ExpectExec(uStmt).WithArgs(capture{&key}, int64(5), "text/plain; charset=utf-8", false, int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "location": "/api/v1/slips/1", "size": 5}`, rec.Body.String())
+
+		// the presigned key is gone, so a late PUT cannot reach the stored copy
+		assert.NotEqual(t, "slips/1/a.jpg", key)
+		_, err = store.Stat(context.Background(), "slips/1/a.jpg")
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+		info, err := store.Stat(context.Background(), key)
 		assert.NoError(t, err)
-		assert.Equal(t, "location/on/s3/bucket/eslip1.jpg", loc)
+		assert.Equal(t, int64(5), info.Size)
 	})
 
+	t.Run("should quarantine an infected presigned upload", func(t *testing.T) {
+		root := t.TempDir()
+		store := NewLocalStorage(root, "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", strings.NewReader("bad"))
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, m, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		m.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "pending"))
+		m.ExpectExec(aStmt).
+			WithArgs(int64(1), sql.NullInt64{Int64: 1, Valid: true}, "quarantine/slips/1/a.jpg", "quarantined", "Eicar-Signature").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		m.ExpectExec(qStmt).WithArgs("quarantine/slips/1/a.jpg", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		scanner := stubScanner{verdict: Verdict{Infected: true, Signature: "Eicar-Signature"}}
+		err := New(db, store, scanner, time.Minute).Complete(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.From(err).Status)
+		assert.NoError(t, m.ExpectationsWereMet())
+		_, err = store.Stat(context.Background(), "slips/1/a.jpg")
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+		_, err = store.Stat(context.Background(), "quarantine/slips/1/a.jpg")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject an oversize upload instead of truncating it", func(t *testing.T) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "slips/1/a.jpg", bytes.NewReader(make([]byte, maxUploadSize+1)))
+
+		_, err := setup(t, store, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "pending"))
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, problem.From(err).Status)
+		_, err = store.Stat(context.Background(), "slips/1/a.jpg")
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("should refuse to complete a quarantined slip again", func(t *testing.T) {
+		store := NewLocalStorage(t.TempDir(), "secret")
+		store.Put(context.Background(), "quarantine/slips/1/a.jpg", strings.NewReader("bad"))
+
+		_, err := setup(t, store, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "quarantined"))
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusConflict, problem.From(err).Status)
+		assert.Equal(t, "slip_not_pending", problem.From(err).Code)
+		_, err = store.Stat(context.Background(), "quarantine/slips/1/a.jpg")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return conflict when nothing was uploaded", func(t *testing.T) {
+		_, err := setup(t, NewLocalStorage(t.TempDir(), "secret"), func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "pending"))
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusConflict, problem.From(err).Status)
+	})
+
+	t.Run("should not serve a pending slip", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		auth.SetSpenderID(c, 1)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(int64(1)).WillReturnRows(slipRowsWithStatus(1, "image/jpeg", "pending"))
+
+		err := New(db, NewLocalStorage(t.TempDir(), "secret"), NopScanner{}, time.Minute).Download(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, problem.From(err).Status)
+	})
+}
+
+func TestDisposition(t *testing.T) {
+	for contentType, want := range map[string]string{
+		"image/jpeg":               "inline",
+		"image/heic":               "inline",
+		"application/pdf":          "inline",
+		"image/svg+xml":            "attachment",
+		"text/html; charset=utf-8": "attachment",
+		"text/xml; charset=utf-8":  "attachment",
+		"application/octet-stream": "attachment",
+	} {
+		assert.Equal(t, want, disposition(contentType), contentType)
+	}
 }
@@ -0,0 +1,266 @@
+package eslip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// box is an ISO BMFF box: data is the payload after the size/type header.
+type box struct {
+	typ   string
+	start int // offset of the payload in the file
+	data  []byte
+}
+
+func readBoxes(b []byte, base int) ([]box, error) {
+	var boxes []box
+	for i := 0; i < len(b); {
+		if i+8 > len(b) {
+			return nil, errMalformedImage
+		}
+		size := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		header := 8
+		switch size {
+		case 0:
+			size = len(b) - i
+		case 1:
+			if i+16 > len(b) {
+				return nil, errMalformedImage
+			}
+			// range-check before converting: a huge 64-bit size would
+			// turn negative as an int and slip past the bounds check
+			n := binary.BigEndian.Uint64(b[i+8:])
+			if n > uint64(len(b)-i) {
+				return nil, errMalformedImage
+			}
+			size = int(n)
+			header = 16
+		}
+		if size < header || i+size > len(b) {
+			return nil, errMalformedImage
+		}
+		boxes = append(boxes, box{typ: typ, start: base + i + header, data: b[i+header : i+size]})
+		i += size
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, bx := range boxes {
+		if bx.typ == typ {
+			return bx, true
+		}
+	}
+	return box{}, false
+}
+
+// sanitizeHEIF blanks the payload of every Exif item and XMP ("mime" item
+// with an RDF content type) in a HEIC/HEIF file. The container layout is left
+// untouched, so offsets stay valid without rewriting the file. Orientation in
+// HEIF lives in the irot/imir item properties rather than EXIF, so it is kept.
+func sanitizeHEIF(b []byte) ([]byte, error) {
+	top, err := readBoxes(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := findBox(top, "meta")
+	if !ok || len(meta.data) < 4 {
+		return nil, errMalformedImage
+	}
+	// meta is a full box: skip version and flags
+	children, err := readBoxes(meta.data[4:], meta.start+4)
+	if err != nil {
+		return nil, err
+	}
+
+	iinf, ok := findBox(children, "iinf")
+	if !ok {
+		return b, nil
+	}
+	targets, err := metadataItems(iinf)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return b, nil
+	}
+
+	iloc, ok := findBox(children, "iloc")
+	if !ok {
+		return nil, errMalformedImage
+	}
+	idatStart := -1
+	if idat, ok := findBox(children, "idat"); ok {
+		idatStart = idat.start
+	}
+
+	out := append([]byte(nil), b...)
+	err = itemExtents(iloc, func(item uint32, method int, offset, length uint64) error {
+		if !targets[item] {
+			return nil
+		}
+		base := uint64(0)
+		if method == 1 {
+			if idatStart < 0 {
+				return errMalformedImage
+			}
+			base = uint64(idatStart)
+		} else if method != 0 {
+			return nil
+		}
+		// checked one term at a time so a crafted offset or length cannot
+		// wrap the sum around to something in range
+		size := uint64(len(out))
+		if length == 0 || base > size || offset > size-base || length > size-base-offset {
+			return errMalformedImage
+		}
+		start := base + offset
+		clear(out[start : start+length])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// metadataItems returns the ids of Exif and XMP items listed in iinf.
+func metadataItems(iinf box) (map[uint32]bool, error) {
+	d := iinf.data
+	if len(d) < 6 {
+		return nil, errMalformedImage
+	}
+	skip := 6 // version, flags and 16-bit entry count
+	if d[0] != 0 {
+		skip = 8
+	}
+	if len(d) < skip {
+		return nil, errMalformedImage
+	}
+
+	entries, err := readBoxes(d[skip:], iinf.start+skip)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[uint32]bool{}
+	for _, infe := range entries {
+		e := infe.data
+		if infe.typ != "infe" || len(e) < 4 || e[0] < 2 {
+			continue
+		}
+		var id uint32
+		p := 4
+		if e[0] == 2 {
+			if len(e) < p+2 {
+				return nil, errMalformedImage
+			}
+			id = uint32(binary.BigEndian.Uint16(e[p:]))
+			p += 2
+		} else {
+			if len(e) < p+4 {
+				return nil, errMalformedImage
+			}
+			id = binary.BigEndian.Uint32(e[p:])
+			p += 4
+		}
+		p += 2 // item_protection_index
+		if len(e) < p+4 {
+			return nil, errMalformedImage
+		}
+		typ := string(e[p : p+4])
+		p += 4
+
+		switch typ {
+		case "Exif":
+			items[id] = true
+		case "mime":
+			// item_name then content_type, both null terminated
+			fields := bytes.SplitN(e[p:], []byte{0}, 3)
+			if len(fields) >= 2 && bytes.Contains(fields[1], []byte("rdf+xml")) {
+				items[id] = true
+			}
+		}
+	}
+	return items, nil
+}
+
+// itemExtents walks every extent in an iloc box.
+func itemExtents(iloc box, fn func(item uint32, method int, offset, length uint64) error) error {
+	d := iloc.data
+	if len(d) < 8 {
+		return errMalformedImage
+	}
+	version := d[0]
+	offsetSize := int(d[4] >> 4)
+	lengthSize := int(d[4] & 0x0F)
+	baseOffsetSize := int(d[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(d[5] & 0x0F)
+	}
+
+	r := &beReader{b: d, p: 6}
+	var count uint64
+	if version < 2 {
+		count = r.uint(2)
+	} else {
+		count = r.uint(4)
+	}
+
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		var item uint32
+		if version < 2 {
+			item = uint32(r.uint(2))
+		} else {
+			item = uint32(r.uint(4))
+		}
+		method := 0
+		if version == 1 || version == 2 {
+			method = int(r.uint(2) & 0x0F)
+		}
+		r.uint(2) // data_reference_index
+		baseOffset := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+		for x := uint64(0); x < extents && r.err == nil; x++ {
+			r.uint(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			if r.err != nil {
+				break
+			}
+			if offset > math.MaxUint64-baseOffset {
+				return errMalformedImage
+			}
+			if err := fn(item, method, baseOffset+offset, length); err != nil {
+				return err
+			}
+		}
+	}
+	return r.err
+}
+
+// beReader reads big-endian unsigned integers of 0, 2, 4 or 8 bytes.
+type beReader struct {
+	b   []byte
+	p   int
+	err error
+}
+
+func (r *beReader) uint(size int) uint64 {
+	if r.err != nil || size == 0 {
+		return 0
+	}
+	if r.p+size > len(r.b) {
+		r.err = errMalformedImage
+		return 0
+	}
+	var v uint64
+	for _, c := range r.b[r.p : r.p+size] {
+		v = v<<8 | uint64(c)
+	}
+	r.p += size
+	return v
+}
@@ -0,0 +1,124 @@
+package eslip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bmffBox(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, typ...), body...)
+}
+
+// newHEIC builds a minimal HEIC file with an image item (1), an Exif item (2)
+// and an XMP item (3), all stored in mdat.
+func newHEIC() []byte {
+	pixels := []byte("PIXELDATA")
+	exif := append([]byte("\x00\x00\x00\x06Exif\x00\x00"), exifTIFF(1)...)
+	xmp := []byte("<x:xmpmeta>GPS</x:xmpmeta>")
+
+	infe := func(id uint16, typ string, extra string) []byte {
+		p := []byte{2, 0, 0, 0}
+		p = binary.BigEndian.AppendUint16(p, id)
+		p = append(p, 0, 0)
+		p = append(p, typ...)
+		p = append(p, extra...)
+		return bmffBox("infe", p)
+	}
+	iinf := bmffBox("iinf", []byte{0, 0, 0, 0, 0, 3},
+		infe(1, "hvc1", "\x00"),
+		infe(2, "Exif", "\x00"),
+		infe(3, "mime", "\x00application/rdf+xml\x00"),
+	)
+
+	ftyp := bmffBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	// iloc is built twice: the first pass only fixes the size of meta so the
+	// mdat offsets in the second pass are right
+	build := func(mdatStart int) []byte {
+		iloc := []byte{0, 0, 0, 0, 0x44, 0x00}
+		iloc = binary.BigEndian.AppendUint16(iloc, 3)
+		offset := mdatStart
+		for id, data := range [][]byte{pixels, exif, xmp} {
+			iloc = binary.BigEndian.AppendUint16(iloc, uint16(id+1))
+			iloc = append(iloc, 0, 0) // data_reference_index
+			iloc = binary.BigEndian.AppendUint16(iloc, 1)
+			iloc = binary.BigEndian.AppendUint32(iloc, uint32(offset))
+			iloc = binary.BigEndian.AppendUint32(iloc, uint32(len(data)))
+			offset += len(data)
+		}
+		meta := bmffBox("meta", []byte{0, 0, 0, 0}, iinf, bmffBox("iloc", iloc))
+		mdat := bmffBox("mdat", pixels, exif, xmp)
+		return bytes.Join([][]byte{ftyp, meta, mdat}, nil)
+	}
+
+	first := build(0)
+	return build(len(first) - len(pixels) - len(exif) - len(xmp))
+}
+
+func TestSanitizeHEIF(t *testing.T) {
+	t.Run("should blank exif and xmp items in place", func(t *testing.T) {
+		src := newHEIC()
+		assert.Equal(t, "image/heic", detectContentType(src))
+
+		out, ok, err := sanitize("image/heic", src)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Len(t, out, len(src))
+		assert.Contains(t, string(out), "PIXELDATA")
+		assert.NotContains(t, string(out), "GPS")
+		assert.NotContains(t, string(out), "xmpmeta")
+	})
+
+	t.Run("should not modify the input slice", func(t *testing.T) {
+		src := newHEIC()
+
+		sanitize("image/heic", src)
+
+		assert.Contains(t, string(src), "GPS")
+	})
+
+	t.Run("should reject a 64-bit box size past the end of the file", func(t *testing.T) {
+		huge := binary.BigEndian.AppendUint32(nil, 1)
+		huge = append(huge, "free"...)
+		huge = binary.BigEndian.AppendUint64(huge, math.MaxInt64)
+		src := append(bmffBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")), huge...)
+
+		_, _, err := sanitize("image/heic", src)
+
+		assert.ErrorIs(t, err, errMalformedImage)
+	})
+
+	t.Run("should reject an item extent that wraps around", func(t *testing.T) {
+		iinf := bmffBox("iinf", []byte{0, 0, 0, 0, 0, 1},
+			bmffBox("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif\x00")))
+		iloc := []byte{0, 0, 0, 0, 0x88, 0x00}
+		iloc = binary.BigEndian.AppendUint16(iloc, 1)
+		iloc = binary.BigEndian.AppendUint16(iloc, 1)
+		iloc = append(iloc, 0, 0) // data_reference_index
+		iloc = binary.BigEndian.AppendUint16(iloc, 1)
+		iloc = binary.BigEndian.AppendUint64(iloc, 10)
+		iloc = binary.BigEndian.AppendUint64(iloc, math.MaxUint64)
+		src := bytes.Join([][]byte{
+			bmffBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")),
+			bmffBox("meta", []byte{0, 0, 0, 0}, iinf, bmffBox("iloc", iloc)),
+		}, nil)
+
+		_, _, err := sanitize("image/heic", src)
+
+		assert.ErrorIs(t, err, errMalformedImage)
+	})
+
+	t.Run("should reject a truncated file", func(t *testing.T) {
+		src := newHEIC()
+
+		_, _, err := sanitize("image/heic", src[:40])
+
+		assert.ErrorIs(t, err, errMalformedImage)
+	})
+}
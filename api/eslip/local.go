@@ -0,0 +1,166 @@
+package eslip
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// LocalUploadPath is where ReceiveUpload must be mounted for presigned
+// URLs issued by LocalStorage to resolve.
+const LocalUploadPath = "/api/v1/slips/uploads"
+
+const maxUploadSize = 50 << 20
+
+type LocalStorage struct {
+	root   string
+	secret string
+}
+
+func NewLocalStorage(root, secret string) *LocalStorage {
+	return &LocalStorage{root: root, secret: secret}
+}
+
+// path maps an object key to a file under root. Keys are cleaned as absolute
+// paths first so "../" can never escape the root directory.
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	name := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	return f, ObjectInfo{Size: st.Size(), ModTime: st.ModTime()}, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	st, err := os.Stat(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{Size: st.Size(), ModTime: st.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Ping makes sure the root directory exists, since a missing volume mount
+// would otherwise only show up on the first upload.
+func (s *LocalStorage) Ping(ctx context.Context) error {
+	return os.MkdirAll(s.root, 0o750)
+}
+
+// Presign returns a relative URL under LocalUploadPath signed with an HMAC of
+// the key, content type, size and expiry.
+func (s *LocalStorage) Presign(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	if s.secret == "" {
+		return "", errors.New("presign secret is not configured")
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", s.sign(key, contentType, size, expires))
+
+	return LocalUploadPath + "/" + key + "?" + q.Encode(), nil
+}
+
+func (s *LocalStorage) sign(key, contentType string, size int64, expires string) string {
+	m := hmac.New(sha256.New, []byte(s.secret))
+	m.Write([]byte(http.MethodPut + "\n" + key + "\n" + contentType + "\n" + strconv.FormatInt(size, 10) + "\n" + expires))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// ReceiveUpload accepts a PUT to a URL issued by Presign. It is the local
+// stand-in for a presigned S3 PUT, so it is authorised by the signature only.
+func (s *LocalStorage) ReceiveUpload(c echo.Context) error {
+	logger := mlog.L(c)
+
+	key := c.Param("*")
+	expires := c.QueryParam("expires")
+	signature := c.QueryParam("signature")
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+
+	// a chunked body has no length (-1) and never matches the signed size
+	size := c.Request().ContentLength
+	if s.secret == "" || !hmac.Equal([]byte(signature), []byte(s.sign(key, contentType, size, expires))) {
+		logger.Warn("invalid upload signature", zap.String("key", key))
+		return problem.Forbidden("invalid_signature", "invalid signature")
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return problem.Forbidden("upload_expired", "upload url has expired")
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxUploadSize)
+	if err := s.Put(c.Request().Context(), key, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return problem.New(http.StatusRequestEntityTooLarge, "upload_too_large", "upload is too large")
+		}
+		logger.Error("store error", zap.Error(err))
+		return problem.Internal(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
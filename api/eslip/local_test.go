@@ -0,0 +1,138 @@
+package eslip
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	t.Run("should put and open an object", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+
+		err := s.Put(context.Background(), "slips/1/a.jpg", strings.NewReader("hello"))
+		assert.NoError(t, err)
+
+		f, info, err := s.Open(context.Background(), "slips/1/a.jpg")
+		assert.NoError(t, err)
+		defer f.Close()
+
+		b, _ := io.ReadAll(f)
+		assert.Equal(t, "hello", string(b))
+		assert.Equal(t, int64(5), info.Size)
+	})
+
+	t.Run("should return not found for missing object", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+
+		_, _, err := s.Open(context.Background(), "missing")
+
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("should create the root directory on ping", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "store")
+		s := NewLocalStorage(root, "secret")
+
+		assert.NoError(t, s.Ping(context.Background()))
+
+		st, err := os.Stat(root)
+		assert.NoError(t, err)
+		assert.True(t, st.IsDir())
+	})
+
+	t.Run("should keep keys inside the root directory", func(t *testing.T) {
+		root := t.TempDir()
+		s := NewLocalStorage(filepath.Join(root, "store"), "secret")
+
+		err := s.Put(context.Background(), "../../escape", strings.NewReader("x"))
+		assert.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(root, "escape"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(root, "store", "escape"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestLocalPresignedUpload(t *testing.T) {
+	upload := func(t *testing.T, s *LocalStorage, url, contentType string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.HTTPErrorHandler = problem.Handler
+		defer e.Close()
+		e.PUT(LocalUploadPath+"/*", s.ReceiveUpload)
+
+		req := httptest.NewRequest(http.MethodPut, url, strings.NewReader("pdf-bytes"))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should accept an upload to a presigned url", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+		url, err := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, time.Minute)
+		assert.NoError(t, err)
+
+		rec := upload(t, s, url, "application/pdf")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		info, err := s.Stat(context.Background(), "slips/1/a.pdf")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9), info.Size)
+	})
+
+	t.Run("should reject a different content type", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+		url, _ := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, time.Minute)
+
+		rec := upload(t, s, url, "image/png")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should reject a body of another size", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+		url, _ := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 4, time.Minute)
+
+		rec := upload(t, s, url, "application/pdf")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should reject an expired url", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+		url, _ := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, -time.Minute)
+
+		rec := upload(t, s, url, "application/pdf")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should reject a tampered key", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "secret")
+		url, _ := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, time.Minute)
+
+		rec := upload(t, s, strings.Replace(url, "slips/1/", "slips/2/", 1), "application/pdf")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should refuse to presign without a secret", func(t *testing.T) {
+		s := NewLocalStorage(t.TempDir(), "")
+
+		_, err := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, time.Minute)
+
+		assert.Error(t, err)
+	})
+}
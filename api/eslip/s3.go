@@ -0,0 +1,215 @@
+package eslip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+type S3Storage struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+// NewS3Storage talks to AWS S3, or to any S3-compatible service when
+// cfg.S3Endpoint is set (usually together with cfg.S3PathStyle). Credentials
+// come from the SDK's default chain (env, shared files, IRSA, instance role)
+// unless cfg sets a static key. Calls carry the trace context of the request
+// they are made for.
+func NewS3Storage(ctx context.Context, cfg config.Storage) (*S3Storage, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.S3Region)}
+	if cfg.S3AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.S3AccessKey, cfg.S3SecretKey, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+
+	// the SDK's own client keeps settings such as AWS_CA_BUNDLE, and is
+	// only set when there are some
+	base := awsCfg.HTTPClient
+	if base == nil {
+		base = awshttp.NewBuildableClient()
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.HTTPClient = &http.Client{Transport: mlog.Transport(doer{base})}
+		o.UsePathStyle = cfg.S3PathStyle
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+		}
+	})
+	return &S3Storage{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  cfg.S3Bucket,
+	}, nil
+}
+
+// doer lets mlog.Transport wrap the SDK's HTTP client.
+type doer struct {
+	aws.HTTPClient
+}
+
+func (d doer) RoundTrip(req *http.Request) (*http.Response, error) {
+	return d.Do(req)
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader) error {
+	// the SDK has to hash the payload for signing, which needs a seekable body
+	body, ok := r.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	return err
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, s3Error(err)
+	}
+
+	info := ObjectInfo{Size: aws.ToInt64(out.ContentLength)}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+	return info, nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	return &s3Object{ctx: ctx, s: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Storage) Presign(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return req.URL, nil
+}
+
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+func s3Error(err error) error {
+	var ae smithy.APIError
+	if errors.As(err, &ae) && (ae.ErrorCode() == "NotFound" || ae.ErrorCode() == "NoSuchKey") {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+// s3Object is a seekable view over an S3 object. Nothing is fetched until the
+// first Read, which issues a ranged GET from the current offset, so serving a
+// Range request only transfers the bytes asked for.
+type s3Object struct {
+	ctx    context.Context
+	s      *S3Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		out, err := o.s.client.GetObject(o.ctx, &s3.GetObjectInput{
+			Bucket: aws.String(o.s.bucket),
+			Key:    aws.String(o.key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-", o.offset)),
+		})
+		if err != nil {
+			return 0, s3Error(err)
+		}
+		o.body = out.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.offset + offset
+	case io.SeekEnd:
+		pos = o.size + offset
+	default:
+		return 0, errors.New("s3object: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("s3object: negative position")
+	}
+
+	if pos != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	return o.body.Close()
+}
@@ -0,0 +1,177 @@
+package eslip
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal path-style S3 endpoint that keeps objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    int
+	header  http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.header = r.Header.Clone()
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = b
+		w.WriteHeader(http.StatusOK)
+	case http.MethodHead, http.MethodGet:
+		if r.URL.Path == "/slips" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		b, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			f.gets++
+		}
+		http.ServeContent(w, r, "", time.Unix(0, 0), bytes.NewReader(b))
+	}
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	f := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3Storage(context.Background(), config.Storage{
+		S3Bucket:    "slips",
+		S3Region:    "us-east-1",
+		S3Endpoint:  srv.URL,
+		S3AccessKey: "key",
+		S3SecretKey: "secret",
+		S3PathStyle: true,
+	})
+	require.NoError(t, err)
+	return f, s
+}
+
+func TestS3Storage(t *testing.T) {
+	t.Run("should put and stat an object", func(t *testing.T) {
+		f, s := newFakeS3(t)
+
+		err := s.Put(context.Background(), "slips/1/a.jpg", strings.NewReader("0123456789"))
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(f.objects["/slips/slips/1/a.jpg"]))
+
+		info, err := s.Stat(context.Background(), "slips/1/a.jpg")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), info.Size)
+	})
+
+	t.Run("should carry the request trace to s3", func(t *testing.T) {
+		f, s := newFakeS3(t)
+		trace := mlog.Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: "01"}
+
+		err := s.Put(mlog.WithTrace(context.Background(), trace), "a.jpg", strings.NewReader("0123456789"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, trace.Traceparent(), f.header.Get("traceparent"))
+	})
+
+	t.Run("should read from the seeked offset with a ranged get", func(t *testing.T) {
+		f, s := newFakeS3(t)
+		f.objects["/slips/a.jpg"] = []byte("0123456789")
+
+		obj, info, err := s.Open(context.Background(), "a.jpg")
+		assert.NoError(t, err)
+		defer obj.Close()
+		assert.Equal(t, int64(10), info.Size)
+
+		obj.Seek(6, io.SeekStart)
+		b, err := io.ReadAll(obj)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "6789", string(b))
+		assert.Equal(t, 1, f.gets)
+	})
+
+	t.Run("should map missing objects to ErrObjectNotFound", func(t *testing.T) {
+		_, s := newFakeS3(t)
+
+		_, err := s.Stat(context.Background(), "missing")
+
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("should presign a put for the content type", func(t *testing.T) {
+		_, s := newFakeS3(t)
+
+		url, err := s.Presign(context.Background(), "slips/1/a.pdf", "application/pdf", 9, time.Minute)
+
+		assert.NoError(t, err)
+		assert.Contains(t, url, "content-length")
+		assert.Contains(t, url, "/slips/slips/1/a.pdf")
+		assert.Contains(t, url, "X-Amz-Signature=")
+		assert.Contains(t, url, "X-Amz-Expires=60")
+	})
+
+	t.Run("should sign with the default credential chain without a static key", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "from-env")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		s, err := NewS3Storage(context.Background(), config.Storage{S3Bucket: "slips", S3Region: "ap-southeast-1"})
+		require.NoError(t, err)
+
+		url, err := s.Presign(context.Background(), "a.pdf", "application/pdf", 9, time.Minute)
+
+		assert.NoError(t, err)
+		assert.Contains(t, url, "X-Amz-Credential=from-env")
+	})
+
+	t.Run("should ping the bucket", func(t *testing.T) {
+		_, s := newFakeS3(t)
+
+		assert.NoError(t, s.Ping(context.Background()))
+
+		s.bucket = "missing"
+		assert.Error(t, s.Ping(context.Background()))
+	})
+}
+
+func TestNewStorage(t *testing.T) {
+	t.Run("should default to local storage", func(t *testing.T) {
+		s, err := NewStorage(context.Background(), config.Storage{LocalRoot: t.TempDir()})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &LocalStorage{}, s)
+	})
+
+	t.Run("should require a bucket for s3", func(t *testing.T) {
+		_, err := NewStorage(context.Background(), config.Storage{Backend: "s3"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unknown backend", func(t *testing.T) {
+		_, err := NewStorage(context.Background(), config.Storage{Backend: "ftp"})
+
+		assert.Error(t, err)
+	})
+}
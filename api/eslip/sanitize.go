@@ -0,0 +1,232 @@
+package eslip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var errMalformedImage = errors.New("malformed image")
+
+// detectContentType is http.DetectContentType plus HEIC/HEIF, which the
+// standard library does not recognise.
+func detectContentType(b []byte) string {
+	if len(b) >= 12 && string(b[4:8]) == "ftyp" {
+		switch string(b[8:12]) {
+		case "heic", "heix", "heim", "heis", "hevc", "hevx":
+			return "image/heic"
+		case "mif1", "msf1", "heif":
+			return "image/heif"
+		}
+	}
+	return http.DetectContentType(b)
+}
+
+// sanitize removes EXIF, XMP, GPS and comment metadata from JPEG, PNG and
+// HEIC/HEIF images. The second result reports whether the content type is one
+// we sanitise; other types are returned unchanged. When the EXIF orientation
+// is not the default the pixels are rotated first, so the stored image still
+// displays the right way up once the tag is gone.
+func sanitize(contentType string, b []byte) ([]byte, bool, error) {
+	var out []byte
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		out, err = sanitizeJPEG(b)
+	case "image/png":
+		out, err = sanitizePNG(b)
+	case "image/heic", "image/heif":
+		out, err = sanitizeHEIF(b)
+	default:
+		return b, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+func sanitizeJPEG(b []byte) ([]byte, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return nil, errMalformedImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(b[:2])
+	orientation := 1
+
+	i := 2
+	for {
+		if i+4 > len(b) || b[i] != 0xFF {
+			return nil, errMalformedImage
+		}
+		marker := b[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA { // start of scan: the rest is entropy-coded data
+			out.Write(b[i:])
+			break
+		}
+
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		end := i + 2 + n
+		if n < 2 || end > len(b) {
+			return nil, errMalformedImage
+		}
+		payload := b[i+4 : end]
+
+		switch marker {
+		case 0xE1: // APP1: EXIF or XMP
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(payload[6:])
+			}
+		case 0xED, 0xFE: // APP13 (IPTC/Photoshop) and comments
+		default:
+			out.Write(b[i:end])
+		}
+		i = end
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), nil
+	}
+
+	img, err := decodeBounded(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orient(img, orientation), &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func sanitizePNG(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, pngSignature) {
+		return nil, errMalformedImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(pngSignature)
+	orientation := 1
+
+	for i := len(pngSignature); i < len(b); {
+		if i+8 > len(b) {
+			return nil, errMalformedImage
+		}
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		end := i + 12 + n
+		if n < 0 || end > len(b) {
+			return nil, errMalformedImage
+		}
+
+		switch typ {
+		case "eXIf":
+			orientation = exifOrientation(b[i+8 : i+8+n])
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(b[i:end])
+		}
+		i = end
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), nil
+	}
+
+	img, err := decodeBounded(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, orient(img, orientation)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exifOrientation reads tag 0x0112 from IFD0 of a TIFF-structured EXIF block.
+// It returns 1 (no transform) when the tag is missing or unreadable.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < count; e++ {
+		p := ifd + 2 + e*12
+		if p+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[p:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[p+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient applies an EXIF orientation (2-8) to img so it displays upright
+// without the tag.
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,172 @@
+package eslip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifTIFF builds a big-endian TIFF block with an orientation tag and a GPS
+// IFD pointer followed by a recognisable payload.
+func exifTIFF(orientation uint16) []byte {
+	b := []byte("MM\x00\x2A\x00\x00\x00\x08")
+	b = binary.BigEndian.AppendUint16(b, 2)
+	b = append(b, 0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1)
+	b = binary.BigEndian.AppendUint16(b, orientation)
+	b = append(b, 0, 0)
+	b = append(b, 0x88, 0x25, 0x00, 0x04, 0, 0, 0, 1, 0, 0, 0, 38)
+	b = append(b, 0, 0, 0, 0)
+	return append(b, "GPS 13.7563N 100.5018E"...)
+}
+
+func withJPEGSegment(t *testing.T, img []byte, marker byte, payload []byte) []byte {
+	t.Helper()
+	seg := []byte{0xFF, marker}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	seg = append(seg, payload...)
+	return append(append(append([]byte{}, img[:2]...), seg...), img[2:]...)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	c := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(c, uint32(len(data)))
+	copy(c[4:], typ)
+	c = append(c, data...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+func newPNG(t *testing.T, w, h int, extra ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// insert after the IHDR chunk (8 byte signature + 25 byte chunk)
+	out := append([]byte{}, b[:33]...)
+	for _, c := range extra {
+		out = append(out, c...)
+	}
+	return append(out, b[33:]...)
+}
+
+func TestDetectContentType(t *testing.T) {
+	heic := append([]byte("\x00\x00\x00\x18ftypheic"), make([]byte, 12)...)
+
+	assert.Equal(t, "image/heic", detectContentType(heic))
+	assert.Equal(t, "application/pdf", detectContentType([]byte("%PDF-1.4 hello")))
+	assert.Equal(t, "image/png", detectContentType(newPNG(t, 1, 1)))
+}
+
+func TestSanitizeJPEG(t *testing.T) {
+	t.Run("should drop exif, xmp and comments", func(t *testing.T) {
+		src := newJPEG(t, 8, 4)
+		src = withJPEGSegment(t, src, 0xE1, append([]byte("Exif\x00\x00"), exifTIFF(1)...))
+		src = withJPEGSegment(t, src, 0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+		src = withJPEGSegment(t, src, 0xFE, []byte("shot on a phone"))
+
+		out, ok, err := sanitize("image/jpeg", src)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotContains(t, string(out), "GPS")
+		assert.NotContains(t, string(out), "xmpmeta")
+		assert.NotContains(t, string(out), "shot on a phone")
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 8, 4), img.Bounds())
+	})
+
+	t.Run("should rotate pixels according to the orientation tag", func(t *testing.T) {
+		src := newJPEG(t, 8, 4)
+		src = withJPEGSegment(t, src, 0xE1, append([]byte("Exif\x00\x00"), exifTIFF(6)...))
+
+		out, _, err := sanitize("image/jpeg", src)
+
+		assert.NoError(t, err)
+		assert.NotContains(t, string(out), "GPS")
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 4, 8), img.Bounds())
+	})
+
+	t.Run("should reject data that is not a jpeg", func(t *testing.T) {
+		_, _, err := sanitize("image/jpeg", []byte("not a jpeg"))
+
+		assert.ErrorIs(t, err, errMalformedImage)
+	})
+}
+
+func TestSanitizePNG(t *testing.T) {
+	t.Run("should drop text, time and exif chunks", func(t *testing.T) {
+		src := newPNG(t, 3, 2,
+			pngChunk("tEXt", []byte("Comment\x00device serial 1234")),
+			pngChunk("eXIf", exifTIFF(1)),
+		)
+
+		out, ok, err := sanitize("image/png", src)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotContains(t, string(out), "serial")
+		assert.NotContains(t, string(out), "GPS")
+		img, err := png.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 3, 2), img.Bounds())
+	})
+
+	t.Run("should apply exif orientation before dropping it", func(t *testing.T) {
+		src := newPNG(t, 3, 2, pngChunk("eXIf", exifTIFF(8)))
+
+		out, _, err := sanitize("image/png", src)
+
+		assert.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 2, 3), img.Bounds())
+	})
+}
+
+func TestSanitizeOtherTypes(t *testing.T) {
+	src := []byte("%PDF-1.4 hello")
+
+	out, ok, err := sanitize("application/pdf", src)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, src, out)
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	t.Run("6 rotates clockwise", func(t *testing.T) {
+		dst := orient(src, 6)
+
+		assert.Equal(t, image.Rect(0, 0, 1, 2), dst.Bounds())
+		r, _, _, _ := dst.At(0, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+	})
+
+	t.Run("8 rotates counter clockwise", func(t *testing.T) {
+		dst := orient(src, 8)
+
+		r, _, _, _ := dst.At(0, 1).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+	})
+
+	t.Run("3 rotates half a turn", func(t *testing.T) {
+		dst := orient(src, 3)
+
+		r, _, _, _ := dst.At(1, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+	})
+}
@@ -0,0 +1,124 @@
+package eslip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+type Verdict struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner inspects an upload before it is committed to storage.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Verdict, error)
+}
+
+// NopScanner accepts everything. It is the default when no scanner is set up.
+type NopScanner struct{}
+
+func (NopScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	return Verdict{}, nil
+}
+
+// NewScanner builds the scanner selected by cfg.Backend.
+func NewScanner(cfg config.Scanner) (Scanner, error) {
+	switch cfg.Backend {
+	case "", "none":
+		return NopScanner{}, nil
+	case "clamd":
+		return NewClamdScanner(cfg.ClamdAddr, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown scanner backend %q", cfg.Backend)
+	}
+}
+
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner streams files to a clamd daemon with the INSTREAM command.
+// addr is host:port, or a path for a unix socket.
+type ClamdScanner struct {
+	addr    string
+	timeout time.Duration
+}
+
+func NewClamdScanner(addr string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{addr: addr, timeout: timeout}
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	network := "tcp"
+	if strings.HasPrefix(s.addr, "/") {
+		network = "unix"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, s.addr)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer conn.Close()
+
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Verdict{}, err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Verdict{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Verdict{}, err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Verdict{}, err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Verdict{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Verdict{}, err
+	}
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamdReply reads replies like "stream: OK" and
+// "stream: Eicar-Test-Signature FOUND".
+func parseClamdReply(reply string) (Verdict, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return Verdict{}, fmt.Errorf("clamd: %s", result)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build integration
+
+package eslip
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClamdScannerIT(t *testing.T) {
+	addr := config.Env("DOCKER_SCANNER_CLAMD_ADDR")
+	if addr == "" {
+		t.Skip("DOCKER_SCANNER_CLAMD_ADDR is not set")
+	}
+	s := NewClamdScanner(addr, 30*time.Second)
+
+	t.Run("clean file passes", func(t *testing.T) {
+		v, err := s.Scan(context.Background(), strings.NewReader("just a receipt"))
+
+		assert.NoError(t, err)
+		assert.False(t, v.Infected)
+	})
+
+	t.Run("eicar test file is detected", func(t *testing.T) {
+		v, err := s.Scan(context.Background(), strings.NewReader(eicar))
+
+		assert.NoError(t, err)
+		assert.True(t, v.Infected)
+		assert.Contains(t, v.Signature, "Eicar")
+	})
+}
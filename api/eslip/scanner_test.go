@@ -0,0 +1,123 @@
+package eslip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd speaks enough of the clamd INSTREAM protocol to flag EICAR.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				cmd, _ := r.ReadString(0)
+				if cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var body bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(r, size); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+					io.CopyN(&body, r, int64(n))
+				}
+				if strings.Contains(body.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	t.Run("should report a clean file", func(t *testing.T) {
+		s := NewClamdScanner(fakeClamd(t), time.Second)
+
+		v, err := s.Scan(context.Background(), strings.NewReader("just a receipt"))
+
+		assert.NoError(t, err)
+		assert.False(t, v.Infected)
+	})
+
+	t.Run("should report the signature of an infected file", func(t *testing.T) {
+		s := NewClamdScanner(fakeClamd(t), time.Second)
+
+		v, err := s.Scan(context.Background(), strings.NewReader(eicar))
+
+		assert.NoError(t, err)
+		assert.True(t, v.Infected)
+		assert.Equal(t, "Eicar-Signature", v.Signature)
+	})
+
+	t.Run("should stream files larger than one chunk", func(t *testing.T) {
+		s := NewClamdScanner(fakeClamd(t), time.Second)
+		big := strings.Repeat("a", clamdChunkSize*2) + eicar
+
+		v, err := s.Scan(context.Background(), strings.NewReader(big))
+
+		assert.NoError(t, err)
+		assert.True(t, v.Infected)
+	})
+
+	t.Run("should fail when clamd is unreachable", func(t *testing.T) {
+		s := NewClamdScanner("127.0.0.1:1", time.Second)
+
+		_, err := s.Scan(context.Background(), strings.NewReader("x"))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestParseClamdReply(t *testing.T) {
+	_, err := parseClamdReply("INSTREAM size limit exceeded. ERROR")
+
+	assert.Error(t, err)
+}
+
+func TestNewScanner(t *testing.T) {
+	s, err := NewScanner(config.Scanner{})
+	assert.NoError(t, err)
+	assert.IsType(t, NopScanner{}, s)
+
+	s, err = NewScanner(config.Scanner{Backend: "clamd", ClamdAddr: "localhost:3310"})
+	assert.NoError(t, err)
+	assert.IsType(t, &ClamdScanner{}, s)
+
+	_, err = NewScanner(config.Scanner{Backend: "mcafee"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package eslip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+type ObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is where slip objects are kept. Open must return a seekable reader
+// so downloads can serve byte ranges.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// Presign returns a URL the client can PUT the object to directly
+	// until ttl elapses. The Content-Type and Content-Length are signed, so
+	// the client must send exactly those.
+	Presign(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error)
+	// Ping checks the backend can be reached, for the readiness probe.
+	Ping(ctx context.Context) error
+}
+
+// NewStorage builds the backend selected by cfg.Backend.
+func NewStorage(ctx context.Context, cfg config.Storage) (Storage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalRoot, cfg.PresignSecret), nil
+	case "s3":
+		if cfg.S3Bucket == "" {
+			return nil, errors.New("s3 storage requires a bucket")
+		}
+		return NewS3Storage(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,81 @@
+package eslip
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	defaultThumbnailWidth = 256
+	minThumbnailWidth     = 16
+	maxThumbnailWidth     = 1024
+
+	// maxDecodePixels bounds the images decoded in memory. A few KB of PNG
+	// can declare 60000x60000 pixels, which would take gigabytes to decode.
+	maxDecodePixels = 40_000_000
+)
+
+var errImageTooLarge = errors.New("image is too large")
+
+// decodeBounded decodes r after checking from its header that the image
+// fits in maxDecodePixels.
+func decodeBounded(r io.ReadSeeker) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxDecodePixels/cfg.Height {
+		return nil, errImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// thumbnail scales src down to width pixels wide, keeping the aspect ratio,
+// by averaging every source pixel that falls into a destination pixel.
+// Images that are already narrower than width are returned unchanged.
+func thumbnail(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= width || sh == 0 {
+		return src
+	}
+
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*sh/height
+		y1 := b.Min.Y + (y+1)*sh/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*sw/width
+			x1 := b.Min.X + (x+1)*sw/width
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
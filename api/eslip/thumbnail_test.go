@@ -0,0 +1,69 @@
+package eslip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbnailResize(t *testing.T) {
+	t.Run("should scale down keeping aspect ratio", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+
+		dst := thumbnail(src, 100)
+
+		assert.Equal(t, image.Rect(0, 0, 100, 75), dst.Bounds())
+	})
+
+	t.Run("should average source pixels", func(t *testing.T) {
+		src := image.NewGray(image.Rect(0, 0, 2, 1))
+		src.SetGray(0, 0, color.Gray{Y: 0})
+		src.SetGray(1, 0, color.Gray{Y: 255})
+
+		dst := thumbnail(src, 1)
+
+		r, _, _, _ := dst.At(0, 0).RGBA()
+		assert.InDelta(t, 0x7fff, r, 0x100)
+	})
+
+	t.Run("should not upscale small images", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 50, 50))
+
+		dst := thumbnail(src, 100)
+
+		assert.Equal(t, src, dst)
+	})
+}
+
+// pngHeader is just the signature and IHDR of a PNG declaring w x h pixels,
+// enough for image.DecodeConfig.
+func pngHeader(w, h uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	b := append([]byte(nil), pngSignature...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestDecodeBounded(t *testing.T) {
+	t.Run("should decode an image within the budget", func(t *testing.T) {
+		img, err := decodeBounded(bytes.NewReader(newJPEG(t, 20, 10)))
+
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+	})
+
+	t.Run("should refuse a huge image before decoding it", func(t *testing.T) {
+		_, err := decodeBounded(bytes.NewReader(pngHeader(60000, 60000)))
+
+		assert.ErrorIs(t, err, errImageTooLarge)
+	})
+}
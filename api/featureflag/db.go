@@ -0,0 +1,102 @@
+package featureflag
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+const (
+	gStmt = `SELECT enabled, rules FROM feature_flag WHERE name=$1`
+	aStmt = `SELECT name, enabled, rules FROM feature_flag`
+	sStmt = `INSERT INTO feature_flag (name, enabled, rules) VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET enabled=$2, rules=$3, updated_at=now()`
+)
+
+// DBProvider keeps flags in the feature_flag table so every replica sees the
+// same value. Flags without a row, or that cannot be read, use their config
+// value.
+type DBProvider struct {
+	db       *sql.DB
+	defaults map[string]Flag
+}
+
+func NewDBProvider(db *sql.DB, cfg config.FeatureFlag) *DBProvider {
+	return &DBProvider{db: db, defaults: Defaults(cfg)}
+}
+
+func scanFlag(enabled bool, rules []byte) (Flag, error) {
+	flag := Flag{Enabled: enabled}
+	if len(rules) > 0 {
+		if err := json.Unmarshal(rules, &flag.Rules); err != nil {
+			return Flag{}, err
+		}
+	}
+	return flag, nil
+}
+
+func (p *DBProvider) Get(ctx context.Context, name string) (Flag, error) {
+	var enabled bool
+	var rules []byte
+	err := p.db.QueryRowContext(ctx, gStmt, name).Scan(&enabled, &rules)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p.defaults[name], nil
+	}
+	if err != nil {
+		return p.defaults[name], err
+	}
+
+	flag, err := scanFlag(enabled, rules)
+	if err != nil {
+		return p.defaults[name], err
+	}
+	return flag, nil
+}
+
+func (p *DBProvider) All(ctx context.Context) (map[string]Flag, error) {
+	flags := make(map[string]Flag, len(p.defaults))
+	for name, flag := range p.defaults {
+		flags[name] = flag
+	}
+
+	rows, err := p.db.QueryContext(ctx, aStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var enabled bool
+		var rules []byte
+		if err := rows.Scan(&name, &enabled, &rules); err != nil {
+			return nil, err
+		}
+		if !known(name) {
+			continue
+		}
+		flag, err := scanFlag(enabled, rules)
+		if err != nil {
+			return nil, err
+		}
+		flags[name] = flag
+	}
+
+	return flags, rows.Err()
+}
+
+func (p *DBProvider) Set(ctx context.Context, name string, flag Flag) error {
+	if !known(name) {
+		return errors.New("unknown feature flag " + name)
+	}
+
+	rules, err := json.Marshal(flag.Rules)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx, sStmt, name, flag.Enabled, rules)
+	return err
+}
@@ -0,0 +1,75 @@
+package featureflag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should read the flag from the table", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(CreateSpender).
+			WillReturnRows(sqlmock.NewRows([]string{"enabled", "rules"}).AddRow(true, []byte(`{"percentage": 10}`)))
+
+		p := NewDBProvider(db, config.FeatureFlag{})
+		f, err := p.Get(ctx, CreateSpender)
+
+		assert.NoError(t, err)
+		assert.True(t, f.Enabled)
+		assert.Equal(t, 10, *f.Percentage)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should fall back to config when there is no row", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(gStmt).WithArgs(CreateSpender).WillReturnRows(sqlmock.NewRows([]string{"enabled", "rules"}))
+		mock.ExpectQuery(gStmt).WithArgs(CreateSpender).WillReturnError(assert.AnError)
+
+		p := NewDBProvider(db, config.FeatureFlag{EnableCreateSpender: true})
+
+		f, err := p.Get(ctx, CreateSpender)
+		assert.NoError(t, err)
+		assert.True(t, f.Enabled)
+		f, err = p.Get(ctx, CreateSpender)
+		assert.Error(t, err)
+		assert.True(t, f.Enabled)
+	})
+
+	t.Run("should merge rows over config values", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(aStmt).WillReturnRows(sqlmock.NewRows([]string{"name", "enabled", "rules"}).
+			AddRow(UpdateSpender, true, []byte(`{"environments": ["PROD"]}`)).
+			AddRow("retired_flag", true, []byte(`{}`)))
+
+		p := NewDBProvider(db, config.FeatureFlag{EnableCreateSpender: true})
+		all, err := p.All(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Flag{
+			CreateSpender:     {Enabled: true},
+			CreateTransaction: {},
+			UpdateSpender:     {Enabled: true, Rules: Rules{Environments: []string{"PROD"}}},
+			UpdateTransaction: {},
+		}, all)
+	})
+
+	t.Run("should upsert on set", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectExec(sStmt).WithArgs(CreateSpender, true, []byte(`{"spenders":[1]}`)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p := NewDBProvider(db, config.FeatureFlag{})
+
+		assert.NoError(t, p.Set(ctx, CreateSpender, Flag{Enabled: true, Rules: Rules{Spenders: []int64{1}}}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
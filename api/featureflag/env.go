@@ -0,0 +1,43 @@
+package featureflag
+
+import (
+	"context"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+// EnvProvider serves the flags parsed from the environment. Set changes them
+// in memory only, so they are back to the environment values after a restart.
+type EnvProvider struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+func NewEnvProvider(cfg config.FeatureFlag) *EnvProvider {
+	return &EnvProvider{flags: Defaults(cfg)}
+}
+
+func (p *EnvProvider) Get(ctx context.Context, name string) (Flag, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.flags[name], nil
+}
+
+func (p *EnvProvider) All(ctx context.Context) (map[string]Flag, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	flags := make(map[string]Flag, len(p.flags))
+	for name, flag := range p.flags {
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+func (p *EnvProvider) Set(ctx context.Context, name string, flag Flag) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flags[name] = flag
+	return nil
+}
@@ -0,0 +1,82 @@
+package featureflag
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Evaluator decides whether a flag is on for a request.
+type Evaluator struct {
+	flags Provider
+	env   string
+}
+
+// NewEvaluator evaluates flags from p. env is the deployment name
+// (feature_flag.environment) that environment rules are matched against.
+func NewEvaluator(p Provider, env string) *Evaluator {
+	return &Evaluator{flags: p, env: env}
+}
+
+// Enabled evaluates name for spenderID, which is 0 when the caller is not a
+// known spender, and logs the outcome with the reason for it.
+func (e *Evaluator) Enabled(c echo.Context, name string, spenderID int64) bool {
+	logger := mlog.L(c)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+
+	flag, err := e.flags.Get(ctx, name)
+	if err != nil {
+		logger.Warn("read feature flag error", zap.String("flag", name), zap.Error(err))
+	}
+
+	enabled, reason := flag.evaluate(name, e.env, spenderID)
+	logger.Info("feature flag evaluated",
+		zap.String("flag", name),
+		zap.Int64("spender_id", spenderID),
+		zap.Bool("enabled", enabled),
+		zap.String("reason", reason))
+	return enabled
+}
+
+// evaluate applies the rules in a fixed order: the kill switch, then the
+// environment, then the allowlist, then the percentage.
+func (f Flag) evaluate(name, env string, spenderID int64) (bool, string) {
+	if !f.Enabled {
+		return false, "disabled"
+	}
+
+	if len(f.Environments) > 0 && !slices.ContainsFunc(f.Environments, func(e string) bool {
+		return strings.EqualFold(e, env)
+	}) {
+		return false, "environment"
+	}
+
+	if spenderID != 0 && slices.Contains(f.Spenders, spenderID) {
+		return true, "allowlist"
+	}
+
+	if f.Percentage != nil {
+		return spenderID != 0 && bucket(name, spenderID) < *f.Percentage, "percentage"
+	}
+
+	if len(f.Spenders) > 0 {
+		return false, "allowlist"
+	}
+
+	return true, "enabled"
+}
+
+// bucket places a spender in 0-99 for a flag. The flag name is part of the
+// hash so each rollout picks a different slice of spenders.
+func bucket(name string, spenderID int64) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + strconv.FormatInt(spenderID, 10)))
+	return int(h.Sum32() % 100)
+}
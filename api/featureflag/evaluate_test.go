@@ -0,0 +1,120 @@
+package featureflag
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func percent(p int) *int {
+	return &p
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		flag       Flag
+		env        string
+		spenderID  int64
+		wantOn     bool
+		wantReason string
+	}{
+		{"disabled", Flag{Enabled: false, Rules: Rules{Spenders: []int64{1}}}, "", 1, false, "disabled"},
+		{"enabled for everyone", Flag{Enabled: true}, "", 0, true, "enabled"},
+		{"other environment", Flag{Enabled: true, Rules: Rules{Environments: []string{"DEV"}}}, "PROD", 1, false, "environment"},
+		{"unnamed environment", Flag{Enabled: true, Rules: Rules{Environments: []string{"DEV"}}}, "", 1, false, "environment"},
+		{"matching environment", Flag{Enabled: true, Rules: Rules{Environments: []string{"dev"}}}, "DEV", 1, true, "enabled"},
+		{"allowlisted", Flag{Enabled: true, Rules: Rules{Spenders: []int64{1, 2}, Percentage: percent(0)}}, "", 2, true, "allowlist"},
+		{"not allowlisted", Flag{Enabled: true, Rules: Rules{Spenders: []int64{1, 2}}}, "", 3, false, "allowlist"},
+		{"full rollout", Flag{Enabled: true, Rules: Rules{Percentage: percent(100)}}, "", 3, true, "percentage"},
+		{"no rollout", Flag{Enabled: true, Rules: Rules{Percentage: percent(0)}}, "", 3, false, "percentage"},
+		{"unknown spender in rollout", Flag{Enabled: true, Rules: Rules{Percentage: percent(100)}}, "", 0, false, "percentage"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			on, reason := tc.flag.evaluate(CreateTransaction, tc.env, tc.spenderID)
+
+			assert.Equal(t, tc.wantOn, on)
+			assert.Equal(t, tc.wantReason, reason)
+		})
+	}
+}
+
+func TestPercentageRollout(t *testing.T) {
+	flag := Flag{Enabled: true, Rules: Rules{Percentage: percent(10)}}
+
+	t.Run("should be deterministic", func(t *testing.T) {
+		for id := int64(1); id <= 100; id++ {
+			first, _ := flag.evaluate(CreateTransaction, "", id)
+			second, _ := flag.evaluate(CreateTransaction, "", id)
+			assert.Equal(t, first, second)
+		}
+	})
+
+	t.Run("should reach about the configured share of spenders", func(t *testing.T) {
+		on := 0
+		for id := int64(1); id <= 10000; id++ {
+			if ok, _ := flag.evaluate(CreateTransaction, "", id); ok {
+				on++
+			}
+		}
+		assert.InDelta(t, 1000, on, 150)
+	})
+}
+
+func TestEvaluatorLogs(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	e := echo.New()
+	defer e.Close()
+
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+	c.Set("logger", zap.New(core))
+
+	p := NewEnvProvider(config.FeatureFlag{})
+	p.Set(c.Request().Context(), CreateTransaction, Flag{Enabled: true, Rules: Rules{Spenders: []int64{42}}})
+
+	on := NewEvaluator(p, "LOCAL").Enabled(c, CreateTransaction, 42)
+
+	assert.True(t, on)
+	entries := logs.FilterMessage("feature flag evaluated").All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"flag":       CreateTransaction,
+		"spender_id": int64(42),
+		"enabled":    true,
+		"reason":     "allowlist",
+	}, entries[0].ContextMap())
+}
+
+// deadlineProvider records whether Get was given a deadline.
+type deadlineProvider struct {
+	Provider
+	bounded bool
+}
+
+func (p *deadlineProvider) Get(ctx context.Context, name string) (Flag, error) {
+	_, p.bounded = ctx.Deadline()
+	return Flag{}, nil
+}
+
+func TestEvaluatorQueryTimeout(t *testing.T) {
+	t.Run("should bound the flag lookup by the query timeout", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+		c.Set("logger", zap.NewNop())
+		p := &deadlineProvider{}
+
+		NewEvaluator(p, "LOCAL").Enabled(c, CreateTransaction, 42)
+
+		assert.True(t, p.bounded)
+	})
+}
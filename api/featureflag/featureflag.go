@@ -0,0 +1,172 @@
+package featureflag
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	CreateSpender     = "enable_create_spender"
+	CreateTransaction = "enable_create_transaction"
+	UpdateSpender     = "enable_update_spender"
+	UpdateTransaction = "enable_update_transaction"
+)
+
+// Rules narrow an enabled flag down to some spenders. A flag without rules
+// is on for everyone.
+type Rules struct {
+	// Spenders always get the flag.
+	Spenders []int64 `json:"spenders,omitempty"`
+	// Percentage of spenders, 0-100, picked by hashing the spender id.
+	Percentage *int `json:"percentage,omitempty"`
+	// Environments the flag is limited to, matched against
+	// feature_flag.environment, which is the ENV name unless set.
+	Environments []string `json:"environments,omitempty"`
+}
+
+type Flag struct {
+	Enabled bool `json:"enabled"`
+	Rules
+}
+
+// UnmarshalJSON also accepts a bare boolean as shorthand for a flag without
+// rules, so {"enable_create_spender": true} keeps working.
+func (f *Flag) UnmarshalJSON(b []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(b, &enabled); err == nil {
+		*f = Flag{Enabled: enabled}
+		return nil
+	}
+
+	type flag Flag
+	var v flag
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = Flag(v)
+	return nil
+}
+
+func (f Flag) validate() error {
+	if f.Percentage != nil && (*f.Percentage < 0 || *f.Percentage > 100) {
+		return errors.New("percentage must be between 0 and 100")
+	}
+	return nil
+}
+
+// Provider stores flag definitions. It is asked on every request, so changes
+// made through Set or in the underlying source take effect without a restart.
+// Get returns the flag's config value alongside any error reading the backend.
+type Provider interface {
+	Get(ctx context.Context, name string) (Flag, error)
+	All(ctx context.Context) (map[string]Flag, error)
+	Set(ctx context.Context, name string, flag Flag) error
+}
+
+// Defaults returns the known flags with their values from config.
+func Defaults(cfg config.FeatureFlag) map[string]Flag {
+	return map[string]Flag{
+		CreateSpender:     {Enabled: cfg.EnableCreateSpender},
+		CreateTransaction: {Enabled: cfg.EnableCreateTransaction},
+		UpdateSpender:     {Enabled: cfg.EnableUpdateSpender},
+		UpdateTransaction: {Enabled: cfg.EnableUpdateTransaction},
+	}
+}
+
+// Names lists the known flags in a stable order.
+func Names() []string {
+	names := make([]string, 0, 4)
+	for name := range Defaults(config.FeatureFlag{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func known(name string) bool {
+	_, ok := Defaults(config.FeatureFlag{})[name]
+	return ok
+}
+
+// NewProvider builds the provider selected by cfg.Backend. Every backend
+// falls back to the values in cfg for flags it has no value for.
+func NewProvider(cfg config.FeatureFlag, db *sql.DB) (Provider, error) {
+	switch cfg.Backend {
+	case "", "env":
+		return NewEnvProvider(cfg), nil
+	case "file":
+		return NewFileProvider(cfg.File, cfg), nil
+	case "db":
+		return NewDBProvider(db, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown feature flag backend %q", cfg.Backend)
+	}
+}
+
+type handler struct {
+	flags Provider
+}
+
+func New(flags Provider) *handler {
+	return &handler{flags}
+}
+
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+
+	flags, err := h.flags.All(ctx)
+	if err != nil {
+		logger.Error("read feature flags error", zap.Error(err))
+		return problem.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, flags)
+}
+
+// Update replaces the flags present in a body like
+// {"enable_create_transaction": {"enabled": true, "percentage": 10}} and
+// returns every flag afterwards.
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+
+	var req map[string]Flag
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
+	}
+
+	for name, flag := range req {
+		if !known(name) {
+			return problem.Validation("unknown_feature_flag", fmt.Sprintf("unknown feature flag %q", name))
+		}
+		if err := flag.validate(); err != nil {
+			return problem.Validation("invalid_feature_flag", fmt.Sprintf("feature flag %q: %s", name, err))
+		}
+	}
+
+	for name, flag := range req {
+		if err := h.flags.Set(ctx, name, flag); err != nil {
+			logger.Error("update feature flag error", zap.String("flag", name), zap.Error(err))
+			return problem.Internal(err)
+		}
+		logger.Info("feature flag updated", zap.String("flag", name), zap.Any("value", flag))
+	}
+
+	return h.GetAll(c)
+}
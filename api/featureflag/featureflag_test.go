@@ -0,0 +1,139 @@
+package featureflag
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    Provider
+	}{
+		{"", &EnvProvider{}},
+		{"env", &EnvProvider{}},
+		{"file", &FileProvider{}},
+		{"db", &DBProvider{}},
+	}
+
+	for _, tc := range tests {
+		t.Run("backend "+tc.backend, func(t *testing.T) {
+			p, err := NewProvider(config.FeatureFlag{Backend: tc.backend}, nil)
+
+			assert.NoError(t, err)
+			assert.IsType(t, tc.want, p)
+		})
+	}
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := NewProvider(config.FeatureFlag{Backend: "etcd"}, nil)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestEnvProvider(t *testing.T) {
+	ctx := context.Background()
+	p := NewEnvProvider(config.FeatureFlag{EnableCreateSpender: true})
+
+	f, err := p.Get(ctx, CreateSpender)
+	assert.NoError(t, err)
+	assert.True(t, f.Enabled)
+
+	assert.NoError(t, p.Set(ctx, CreateSpender, Flag{Enabled: true, Rules: Rules{Spenders: []int64{7}}}))
+
+	all, err := p.All(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Flag{
+		CreateSpender:     {Enabled: true, Rules: Rules{Spenders: []int64{7}}},
+		CreateTransaction: {},
+		UpdateSpender:     {},
+		UpdateTransaction: {},
+	}, all)
+}
+
+func TestHandler(t *testing.T) {
+	serve := func(p Provider, method, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.HTTPErrorHandler = problem.Handler
+		defer e.Close()
+
+		h := New(p)
+		e.GET("/", h.GetAll)
+		e.PUT("/", h.Update)
+
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should list every flag", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{EnableCreateTransaction: true})
+
+		rec := serve(p, http.MethodGet, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"enable_create_spender": {"enabled": false},
+			"enable_create_transaction": {"enabled": true},
+			"enable_update_spender": {"enabled": false},
+			"enable_update_transaction": {"enabled": false}
+		}`, rec.Body.String())
+	})
+
+	t.Run("should update flags without a restart", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+
+		rec := serve(p, http.MethodPut, `{"enable_create_spender": true}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"enable_create_spender":{"enabled":true}`)
+		f, _ := p.Get(context.Background(), CreateSpender)
+		assert.True(t, f.Enabled)
+	})
+
+	t.Run("should update rollout rules", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+
+		rec := serve(p, http.MethodPut, `{"enable_create_transaction": {"enabled": true, "percentage": 10, "spenders": [1, 2]}}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		f, _ := p.Get(context.Background(), CreateTransaction)
+		assert.Equal(t, []int64{1, 2}, f.Spenders)
+		assert.Equal(t, 10, *f.Percentage)
+	})
+
+	t.Run("should reject a percentage out of range", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+
+		rec := serve(p, http.MethodPut, `{"enable_create_transaction": {"enabled": true, "percentage": 110}}`)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should reject unknown flags", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+
+		rec := serve(p, http.MethodPut, `{"enable_create_spender": true, "enable_everything": true}`)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		f, _ := p.Get(context.Background(), CreateSpender)
+		assert.False(t, f.Enabled)
+	})
+
+	t.Run("should reject a bad body", func(t *testing.T) {
+		rec := serve(NewEnvProvider(config.FeatureFlag{}), http.MethodPut, `{"enable_create_spender": "yes"}`)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
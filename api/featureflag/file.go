@@ -0,0 +1,126 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+// FileProvider reads flags from a JSON object mapping flag names to a Flag,
+// or to a bare boolean. The file is re-read whenever its modification time
+// changes, so it can be edited in place or replaced by a mounted ConfigMap.
+// A missing file means every flag has its config value.
+type FileProvider struct {
+	path     string
+	defaults map[string]Flag
+
+	mu      sync.Mutex
+	modTime time.Time
+	flags   map[string]Flag
+}
+
+func NewFileProvider(path string, cfg config.FeatureFlag) *FileProvider {
+	return &FileProvider{path: path, defaults: Defaults(cfg)}
+}
+
+// load returns the current flags, keeping the last good copy if the file
+// cannot be read or parsed.
+func (p *FileProvider) load() (map[string]Flag, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadLocked()
+}
+
+func (p *FileProvider) loadLocked() (map[string]Flag, error) {
+	st, err := os.Stat(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		p.flags, p.modTime = nil, time.Time{}
+		return p.merged(), nil
+	}
+	if err != nil {
+		return p.merged(), err
+	}
+	if p.flags != nil && st.ModTime().Equal(p.modTime) {
+		return p.merged(), nil
+	}
+
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return p.merged(), err
+	}
+	flags := map[string]Flag{}
+	if err := json.Unmarshal(b, &flags); err != nil {
+		return p.merged(), err
+	}
+
+	p.flags, p.modTime = flags, st.ModTime()
+	return p.merged(), nil
+}
+
+func (p *FileProvider) merged() map[string]Flag {
+	flags := make(map[string]Flag, len(p.defaults))
+	for name, flag := range p.defaults {
+		flags[name] = flag
+	}
+	for name, flag := range p.flags {
+		if known(name) {
+			flags[name] = flag
+		}
+	}
+	return flags
+}
+
+func (p *FileProvider) Get(ctx context.Context, name string) (Flag, error) {
+	flags, err := p.load()
+	return flags[name], err
+}
+
+func (p *FileProvider) All(ctx context.Context) (map[string]Flag, error) {
+	return p.load()
+}
+
+// Set rewrites the whole file atomically with every flag's current value.
+func (p *FileProvider) Set(ctx context.Context, name string, flag Flag) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	flags, err := p.loadLocked()
+	if err != nil {
+		return err
+	}
+	flags[name] = flag
+
+	b, err := json.MarshalIndent(flags, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), ".flags-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return err
+	}
+
+	p.flags = flags
+	if st, err := os.Stat(p.path); err == nil {
+		p.modTime = st.ModTime()
+	}
+	return nil
+}
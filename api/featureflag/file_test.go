@@ -0,0 +1,70 @@
+package featureflag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func enabled(p Provider, name string) bool {
+	f, _ := p.Get(context.Background(), name)
+	return f.Enabled
+}
+
+func TestFileProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should use config values when the file is missing", func(t *testing.T) {
+		p := NewFileProvider(filepath.Join(t.TempDir(), "flags.json"), config.FeatureFlag{EnableUpdateSpender: true})
+
+		assert.True(t, enabled(p, UpdateSpender))
+		assert.False(t, enabled(p, CreateSpender))
+	})
+
+	t.Run("should pick up edits to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "flags.json")
+		os.WriteFile(path, []byte(`{"enable_create_spender": true}`), 0o600)
+		p := NewFileProvider(path, config.FeatureFlag{})
+
+		assert.True(t, enabled(p, CreateSpender))
+
+		os.WriteFile(path, []byte(`{"enable_create_spender": {"enabled": true, "spenders": [3]}}`), 0o600)
+		later := time.Now().Add(time.Second)
+		os.Chtimes(path, later, later)
+
+		f, err := p.Get(ctx, CreateSpender)
+		assert.NoError(t, err)
+		assert.Equal(t, Flag{Enabled: true, Rules: Rules{Spenders: []int64{3}}}, f)
+	})
+
+	t.Run("should keep the last good flags when the file is broken", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "flags.json")
+		os.WriteFile(path, []byte(`{"enable_create_spender": true}`), 0o600)
+		p := NewFileProvider(path, config.FeatureFlag{})
+		p.Get(ctx, CreateSpender)
+
+		os.WriteFile(path, []byte(`{not json`), 0o600)
+		later := time.Now().Add(time.Second)
+		os.Chtimes(path, later, later)
+
+		f, err := p.Get(ctx, CreateSpender)
+		assert.Error(t, err)
+		assert.True(t, f.Enabled)
+	})
+
+	t.Run("should write flags back to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "flags.json")
+		p := NewFileProvider(path, config.FeatureFlag{})
+
+		err := p.Set(ctx, CreateTransaction, Flag{Enabled: true})
+
+		assert.NoError(t, err)
+		assert.True(t, enabled(p, CreateTransaction))
+		assert.True(t, enabled(NewFileProvider(path, config.FeatureFlag{}), CreateTransaction))
+	})
+}
@@ -0,0 +1,147 @@
+package featureflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// SubjectFunc finds the spender a gated request is for, or 0 if there is
+// none. An error ends the request with it instead of evaluating the flag.
+type SubjectFunc func(c echo.Context) (int64, error)
+
+// maxSubjectBody caps the body SpenderField buffers before any handler runs.
+const maxSubjectBody = 64 << 10
+
+// AuthenticatedSpender uses the spender set by auth.Spender.
+func AuthenticatedSpender(c echo.Context) (int64, error) {
+	id, _ := auth.SpenderID(c)
+	return id, nil
+}
+
+// NoSpender is for routes that act for no spender, such as creating one.
+// Allowlist and percentage rules never enable them.
+func NoSpender(echo.Context) (int64, error) {
+	return 0, nil
+}
+
+// SpenderParam reads the spender id from a path parameter.
+func SpenderParam(name string) SubjectFunc {
+	return func(c echo.Context) (int64, error) {
+		id, _ := strconv.ParseInt(c.Param(name), 10, 64)
+		return id, nil
+	}
+}
+
+// SpenderField uses the spender set by auth.Spender and, on routes that do
+// not authenticate, reads the spender id from a field of the JSON body. The
+// body is put back so the handler can still bind it. A body field is chosen
+// by the caller, who can name a spender on an allowlist or inside a rollout,
+// so flags evaluated from it stage a rollout rather than control access.
+// auth.Spender only counts when it runs before the gate, as group middleware.
+// A body over maxSubjectBody is refused with 413.
+func SpenderField(field string) SubjectFunc {
+	return func(c echo.Context) (int64, error) {
+		if id, ok := auth.SpenderID(c); ok {
+			return id, nil
+		}
+
+		req := c.Request()
+		if req.Body == nil {
+			return 0, nil
+		}
+
+		b, err := io.ReadAll(io.LimitReader(req.Body, maxSubjectBody+1))
+		req.Body.Close()
+		if len(b) > maxSubjectBody {
+			return 0, problem.New(http.StatusRequestEntityTooLarge, "body_too_large", fmt.Sprintf("request body is larger than %d bytes", maxSubjectBody))
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		if err != nil {
+			return 0, nil
+		}
+
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(b, &body); err != nil {
+			return 0, nil
+		}
+		var id int64
+		json.Unmarshal(body[field], &id)
+		return id, nil
+	}
+}
+
+type GatedRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Flag   string `json:"flag"`
+}
+
+// Gate puts routes behind feature flags. Routes are declared through Add so
+// the flag is part of the route definition and shows up in Routes.
+type Gate struct {
+	flags *Evaluator
+
+	mu     sync.Mutex
+	routes []GatedRoute
+}
+
+func NewGate(flags *Evaluator) *Gate {
+	return &Gate{flags: flags}
+}
+
+// Require is middleware answering 403 feature_disabled unless name is
+// enabled for the spender found by subject. A nil subject means
+// AuthenticatedSpender.
+func (g *Gate) Require(name string, subject SubjectFunc) echo.MiddlewareFunc {
+	if subject == nil {
+		subject = AuthenticatedSpender
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id, err := subject(c)
+			if err != nil {
+				return err
+			}
+			if !g.flags.Enabled(c, name, id) {
+				metrics.FlagDenied(name)
+				return problem.Forbidden("feature_disabled", "feature "+name+" is disabled")
+			}
+			return next(c)
+		}
+	}
+}
+
+// Add registers a route on grp that only runs while name is enabled.
+// Middleware in m runs after the flag check.
+func (g *Gate) Add(grp *echo.Group, method, path string, h echo.HandlerFunc, name string, subject SubjectFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	m = append([]echo.MiddlewareFunc{g.Require(name, subject)}, m...)
+	r := grp.Add(method, path, h, m...)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.routes = append(g.routes, GatedRoute{Method: r.Method, Path: r.Path, Flag: name})
+	return r
+}
+
+// Routes returns every route added through Add.
+func (g *Gate) Routes() []GatedRoute {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]GatedRoute{}, g.routes...)
+}
+
+// ListRoutes is the admin endpoint reporting gated routes.
+func (g *Gate) ListRoutes(c echo.Context) error {
+	return c.JSON(http.StatusOK, g.Routes())
+}
@@ -0,0 +1,194 @@
+package featureflag
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGate(t *testing.T) {
+	serve := func(p Provider, method, path, body string, add func(g *Gate, v1 *echo.Group, h echo.HandlerFunc)) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.HTTPErrorHandler = problem.Handler
+		defer e.Close()
+
+		g := NewGate(NewEvaluator(p, ""))
+		add(g, e.Group("/api/v1"), func(c echo.Context) error {
+			b, _ := io.ReadAll(c.Request().Body)
+			return c.String(http.StatusOK, string(b))
+		})
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	createSpender := func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+		g.Add(v1, http.MethodPost, "/spenders", h, CreateSpender, nil)
+	}
+
+	t.Run("should refuse with one error body when the flag is off", func(t *testing.T) {
+		rec := serve(NewEnvProvider(config.FeatureFlag{}), http.MethodPost, "/api/v1/spenders", `{}`, createSpender)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, problem.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Forbidden",
+			"status": 403,
+			"detail": "feature enable_create_spender is disabled",
+			"instance": "/api/v1/spenders",
+			"code": "feature_disabled"
+		}`, rec.Body.String())
+	})
+
+	t.Run("should call the handler when the flag is on", func(t *testing.T) {
+		rec := serve(NewEnvProvider(config.FeatureFlag{EnableCreateSpender: true}), http.MethodPost, "/api/v1/spenders", `{}`, createSpender)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should follow a flag change without a restart", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+		assert.Equal(t, http.StatusForbidden, serve(p, http.MethodPost, "/api/v1/spenders", `{}`, createSpender).Code)
+
+		p.Set(context.Background(), CreateSpender, Flag{Enabled: true})
+
+		assert.Equal(t, http.StatusOK, serve(p, http.MethodPost, "/api/v1/spenders", `{}`, createSpender).Code)
+	})
+
+	t.Run("should enforce update transaction", func(t *testing.T) {
+		rec := serve(NewEnvProvider(config.FeatureFlag{}), http.MethodPut, "/api/v1/transactions/1", `{"spender_id": 1}`, func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			g.Add(v1, http.MethodPut, "/transactions/:id", h, UpdateTransaction, SpenderField("spender_id"))
+		})
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should target the spender in the body and keep the body for the handler", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+		p.Set(context.Background(), CreateTransaction, Flag{Enabled: true, Rules: Rules{Spenders: []int64{7}}})
+		add := func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			g.Add(v1, http.MethodPost, "/transactions", h, CreateTransaction, SpenderField("spender_id"))
+		}
+
+		rec := serve(p, http.MethodPost, "/api/v1/transactions", `{"spender_id": 7, "amount": 10}`, add)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"spender_id": 7, "amount": 10}`, rec.Body.String())
+
+		rec = serve(p, http.MethodPost, "/api/v1/transactions", `{"spender_id": 8, "amount": 10}`, add)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should prefer the authenticated spender over the body", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+		p.Set(context.Background(), CreateTransaction, Flag{Enabled: true, Rules: Rules{Spenders: []int64{7}}})
+		as := func(id int64) func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			return func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+				v1.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+					return func(c echo.Context) error {
+						auth.SetSpenderID(c, id)
+						return next(c)
+					}
+				})
+				g.Add(v1, http.MethodPost, "/transactions", h, CreateTransaction, SpenderField("spender_id"))
+			}
+		}
+
+		assert.Equal(t, http.StatusForbidden, serve(p, http.MethodPost, "/api/v1/transactions", `{"spender_id": 7}`, as(8)).Code)
+		assert.Equal(t, http.StatusOK, serve(p, http.MethodPost, "/api/v1/transactions", `{"spender_id": 8}`, as(7)).Code)
+	})
+
+	t.Run("should not target whoever is authenticated when creating a spender", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+		p.Set(context.Background(), CreateSpender, Flag{Enabled: true, Rules: Rules{Spenders: []int64{7}}})
+		add := func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			v1.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					auth.SetSpenderID(c, 7)
+					return next(c)
+				}
+			})
+			g.Add(v1, http.MethodPost, "/spenders", h, CreateSpender, NoSpender)
+		}
+
+		assert.Equal(t, http.StatusForbidden, serve(p, http.MethodPost, "/api/v1/spenders", `{}`, add).Code)
+
+		p.Set(context.Background(), CreateSpender, Flag{Enabled: true})
+		assert.Equal(t, http.StatusOK, serve(p, http.MethodPost, "/api/v1/spenders", `{}`, add).Code)
+	})
+
+	t.Run("should refuse a body too large to read the spender from", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{EnableCreateTransaction: true})
+		add := func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			g.Add(v1, http.MethodPost, "/transactions", h, CreateTransaction, SpenderField("spender_id"))
+		}
+		body := `{"spender_id": 7, "note": "` + strings.Repeat("x", maxSubjectBody) + `"}`
+
+		rec := serve(p, http.MethodPost, "/api/v1/transactions", body, add)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"body_too_large"`)
+	})
+
+	t.Run("should target the spender in the path", func(t *testing.T) {
+		p := NewEnvProvider(config.FeatureFlag{})
+		p.Set(context.Background(), UpdateSpender, Flag{Enabled: true, Rules: Rules{Spenders: []int64{3}}})
+		add := func(g *Gate, v1 *echo.Group, h echo.HandlerFunc) {
+			g.Add(v1, http.MethodPut, "/spenders/:id", h, UpdateSpender, SpenderParam("id"))
+		}
+
+		assert.Equal(t, http.StatusOK, serve(p, http.MethodPut, "/api/v1/spenders/3", `{}`, add).Code)
+		assert.Equal(t, http.StatusForbidden, serve(p, http.MethodPut, "/api/v1/spenders/4", `{}`, add).Code)
+	})
+}
+
+func TestAuthenticatedSpender(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler
+	defer e.Close()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	id, err := AuthenticatedSpender(c)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), id)
+
+	auth.SetSpenderID(c, 9)
+
+	id, err = AuthenticatedSpender(c)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+}
+
+func TestGateRoutes(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler
+	defer e.Close()
+
+	g := NewGate(NewEvaluator(NewEnvProvider(config.FeatureFlag{}), ""))
+	v1 := e.Group("/api/v1")
+	h := func(c echo.Context) error { return nil }
+	g.Add(v1, http.MethodPost, "/spenders", h, CreateSpender, nil)
+	g.Add(v1, http.MethodPut, "/transactions/:id", h, UpdateTransaction, SpenderField("spender_id"))
+
+	e.GET("/routes", g.ListRoutes)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/routes", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"method": "POST", "path": "/api/v1/spenders", "flag": "enable_create_spender"},
+		{"method": "PUT", "path": "/api/v1/transactions/:id", "flag": "enable_update_transaction"}
+	]`, rec.Body.String())
+}
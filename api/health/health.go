@@ -1,31 +1,138 @@
 package health
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
-func Check(db *sql.DB) func(c echo.Context) error {
-	return func(c echo.Context) error {
-		if err := db.Ping(); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("api server is live: but can't connect to database: %s", err.Error())})
-		}
+// Checker is one dependency the service needs to take traffic.
+type Checker struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+func Database(db *sql.DB) Checker {
+	return Checker{Name: "database", Check: db.PingContext}
+}
+
+// Probes serves the Kubernetes liveness, readiness and startup probes. Only
+// readiness and startup look at dependencies, so a database blip takes pods
+// out of rotation instead of restarting them.
+type Probes struct {
+	timeout  time.Duration
+	checkers []Checker
+
+	started  atomic.Bool
+	draining atomic.Bool
+}
+
+// New runs every check with its own timeout, so one hanging dependency
+// cannot hide the state of the others.
+func New(timeout time.Duration, checkers ...Checker) *Probes {
+	return &Probes{timeout: timeout, checkers: checkers}
+}
+
+// Add registers another check. It must be called before serving.
+func (p *Probes) Add(c Checker) {
+	p.checkers = append(p.checkers, c)
+}
+
+// Drain fails readiness from now on, so traffic moves away before the
+// server shuts down.
+func (p *Probes) Drain() {
+	p.draining.Store(true)
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckReport `json:"checks,omitempty"`
+}
+
+type CheckReport struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Live reports that the process still serves requests, nothing more.
+func (p *Probes) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, Report{Status: "ok"})
+}
+
+// Ready reports every check and fails if any of them does, or once
+// draining has begun.
+func (p *Probes) Ready(c echo.Context) error {
+	if p.draining.Load() {
+		return c.JSON(http.StatusServiceUnavailable, Report{Status: "draining"})
+	}
+	return p.respond(c, p.check(c.Request().Context()))
+}
+
+// Startup runs the checks until they all pass once and from then on passes
+// without checking, leaving the rest to Ready.
+func (p *Probes) Startup(c echo.Context) error {
+	if p.started.Load() {
+		return c.JSON(http.StatusOK, Report{Status: "ok"})
+	}
+
+	r := p.check(c.Request().Context())
+	if r.Status == "ok" {
+		p.started.Store(true)
+	}
+	return p.respond(c, r)
+}
 
-		return c.JSON(http.StatusOK, map[string]string{
-			"status":  "ok",
-			"message": "api is ready and connected to database"})
+func (p *Probes) respond(c echo.Context, r Report) error {
+	if r.Status != "ok" {
+		return c.JSON(http.StatusServiceUnavailable, r)
 	}
+	return c.JSON(http.StatusOK, r)
 }
 
-// Slow is for Demo purpose to simulate slow endpoint
-func Slow(c echo.Context) error {
-	fmt.Println("simulate slow end that takes 10 seconds to respond")
-	time.Sleep(10 * time.Second)
-	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+func (p *Probes) check(ctx context.Context) Report {
+	r := Report{Status: "ok", Checks: make(map[string]CheckReport, len(p.checkers))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range p.checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+			cr := p.run(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			r.Checks[checker.Name] = cr
+			if cr.Status != "ok" {
+				r.Status = "error"
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	return r
+}
+
+func (p *Probes) run(ctx context.Context, checker Checker) CheckReport {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	cr := CheckReport{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		cr.Status = "error"
+		cr.Error = err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			cr.Error = fmt.Sprintf("timed out after %s: %s", p.timeout, err)
+		}
+	}
+	return cr
 }
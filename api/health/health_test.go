@@ -1,26 +1,106 @@
 package health
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	_ "github.com/proullon/ramsql/driver"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestHealthCheck(t *testing.T) {
-	req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+func serve(t *testing.T, h echo.HandlerFunc) (int, Report) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
 	rec := httptest.NewRecorder()
-	e := echo.New()
-	c := e.NewContext(req, rec)
+	c := echo.New().NewContext(req, rec)
+
+	assert.NoError(t, h(c))
+
+	var r Report
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &r))
+	return rec.Code, r
+}
+
+func pass(ctx context.Context) error { return nil }
 
+func TestHealthCheck(t *testing.T) {
 	db, _ := sql.Open("ramsql", "TestHealth")
+	p := New(time.Second, Database(db))
+
+	code, r := serve(t, p.Ready)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", r.Checks["database"].Status)
+}
+
+func TestProbes(t *testing.T) {
+	t.Run("live should not look at dependencies", func(t *testing.T) {
+		p := New(time.Second, Checker{Name: "database", Check: func(ctx context.Context) error { return errors.New("down") }})
+
+		code, r := serve(t, p.Live)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", r.Status)
+	})
+
+	t.Run("ready should report every check by name", func(t *testing.T) {
+		p := New(time.Second, Checker{Name: "database", Check: pass})
+		p.Add(Checker{Name: "storage", Check: func(ctx context.Context) error { return errors.New("bucket missing") }})
+
+		code, r := serve(t, p.Ready)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "error", r.Status)
+		assert.Equal(t, "ok", r.Checks["database"].Status)
+		assert.Equal(t, "bucket missing", r.Checks["storage"].Error)
+	})
+
+	t.Run("ready should time out each check on its own", func(t *testing.T) {
+		p := New(20*time.Millisecond,
+			Checker{Name: "database", Check: pass},
+			Checker{Name: "storage", Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+		)
+
+		code, r := serve(t, p.Ready)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "ok", r.Checks["database"].Status)
+		assert.Contains(t, r.Checks["storage"].Error, "timed out after 20ms")
+	})
+
+	t.Run("ready should fail once draining", func(t *testing.T) {
+		p := New(time.Second, Checker{Name: "database", Check: pass})
+		p.Drain()
+
+		code, r := serve(t, p.Ready)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "draining", r.Status)
+	})
+
+	t.Run("startup should pass for good once the checks have", func(t *testing.T) {
+		err := errors.New("migrations pending")
+		p := New(time.Second, Checker{Name: "migrations", Check: func(ctx context.Context) error { return err }})
+
+		code, _ := serve(t, p.Startup)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
 
-	err := Check(db)(c)
+		err = nil
+		code, _ = serve(t, p.Startup)
+		assert.Equal(t, http.StatusOK, code)
 
-	assert.Nil(t, err)
-	assert.Equal(t, http.StatusOK, rec.Code)
+		err = errors.New("database down")
+		code, _ = serve(t, p.Startup)
+		assert.Equal(t, http.StatusOK, code)
+	})
 }
@@ -0,0 +1,149 @@
+// Package metrics records Prometheus metrics and serves them from Handler,
+// which runs on its own admin port rather than the public API.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hongjot"
+
+// Outcomes of a slip upload, counted by Upload.
+const (
+	UploadStored     = "stored"
+	UploadRejected   = "rejected"
+	UploadInfected   = "infected"
+	UploadScanFailed = "scan_failed"
+	UploadFailed     = "failed"
+)
+
+// Registry holds every metric of the service. It is not the global
+// registry, so nothing a dependency registers leaks onto /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Failed database calls.",
+	})
+
+	transactionsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_created_total",
+		Help:      "Transactions created by transaction type.",
+	}, []string{"type"})
+
+	uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slip_uploads_total",
+		Help:      "Slip uploads by outcome.",
+	}, []string{"outcome"})
+
+	flagDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feature_flag_denials_total",
+		Help:      "Requests refused because a feature flag was off.",
+	}, []string{"flag"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestDuration,
+		dbErrors,
+		transactionsCreated,
+		uploads,
+		flagDenials,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// NewServer returns the admin server answering /metrics on port.
+func NewServer(port string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &http.Server{Addr: ":" + port, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
+
+// RegisterDB adds gauges for the connection pool of db. Registering the
+// same pool again is a no-op.
+func RegisterDB(db *sql.DB, name string) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+// Middleware observes the latency of every request. The route is the
+// template, e.g. /api/v1/spenders/:id, so ids do not blow up the series.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = problem.From(err).Status
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			requestDuration.WithLabelValues(c.Request().Method, route, strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// DBError counts a failed database call.
+func DBError() {
+	dbErrors.Inc()
+}
+
+// TransactionCreated counts a new transaction. Types other than income and
+// expense are counted as other to keep the label bounded.
+func TransactionCreated(transactionType string) {
+	switch transactionType {
+	case "income", "expense":
+	default:
+		transactionType = "other"
+	}
+	transactionsCreated.WithLabelValues(transactionType).Inc()
+}
+
+// Upload counts a slip upload with one of the Upload* outcomes.
+func Upload(outcome string) {
+	uploads.WithLabelValues(outcome).Inc()
+}
+
+// FlagDenied counts a request refused by the feature flag gate.
+func FlagDenied(flag string) {
+	flagDenials.WithLabelValues(flag).Inc()
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/spenders/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, path := range []string{"/spenders/1", "/spenders/2", "/spenders/0", "/missing"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t)
+	assert.Contains(t, out, `hongjot_http_request_duration_seconds_count{method="GET",route="/spenders/:id",status="200"} 2`)
+	assert.Contains(t, out, `hongjot_http_request_duration_seconds_count{method="GET",route="/spenders/:id",status="404"} 1`)
+	assert.NotContains(t, out, `route="/spenders/1"`)
+}
+
+func TestCounters(t *testing.T) {
+	t.Run("counts database errors", func(t *testing.T) {
+		before := testutil.ToFloat64(dbErrors)
+
+		DBError()
+
+		assert.Equal(t, before+1, testutil.ToFloat64(dbErrors))
+	})
+
+	t.Run("keeps transaction types bounded", func(t *testing.T) {
+		before := testutil.ToFloat64(transactionsCreated.WithLabelValues("other"))
+
+		TransactionCreated("income")
+		TransactionCreated("gift")
+
+		assert.Equal(t, before+1, testutil.ToFloat64(transactionsCreated.WithLabelValues("other")))
+		assert.NotContains(t, scrape(t), `type="gift"`)
+	})
+
+	t.Run("counts uploads and flag denials", func(t *testing.T) {
+		Upload(UploadInfected)
+		FlagDenied("enable_create_spender")
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(uploads.WithLabelValues(UploadInfected)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(flagDenials.WithLabelValues("enable_create_spender")))
+	})
+}
+
+func TestRegisterDB(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	assert.NoError(t, RegisterDB(db, "test"))
+	assert.NoError(t, RegisterDB(db, "test"))
+	assert.Contains(t, scrape(t), `go_sql_max_open_connections{db_name="test"}`)
+}
+
+func TestNewServer(t *testing.T) {
+	srv := httptest.NewServer(NewServer("0").Handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(b), "go_goroutines")
+
+	resp, err = http.Get(srv.URL + "/api/v1/health")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return strings.TrimSpace(rec.Body.String())
+}
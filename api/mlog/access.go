@@ -0,0 +1,114 @@
+package mlog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLog logs one line per request through the logger Middleware set up,
+// so it carries the trace ids; it has to run after Middleware. Errors are
+// handed to echo first so the logged status is the one the client got.
+func AccessLog(cfg config.AccessLog) echo.MiddlewareFunc {
+	// the rules were checked when the config was loaded
+	rules, _ := cfg.Rules()
+	r := redactor(rules)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			var reqBody []byte
+			var resBody *capture
+			if cfg.Body {
+				reqBody = peek(req, cfg.MaxBody)
+				resBody = &capture{ResponseWriter: c.Response().Writer, limit: cfg.MaxBody}
+				c.Response().Writer = resBody
+			}
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("path", req.URL.Path),
+				zap.String("route", c.Path()),
+				zap.Int("status", res.Status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int64("bytes_in", req.ContentLength),
+				zap.Int64("bytes_out", res.Size),
+				zap.String("remote_ip", c.RealIP()),
+				zap.String("user_agent", req.UserAgent()),
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+			if cfg.Body {
+				fields = append(fields,
+					r.field("request_body", req.Header.Get(echo.HeaderContentType), reqBody, cfg.MaxBody),
+					r.field("response_body", res.Header().Get(echo.HeaderContentType), resBody.buf.Bytes(), cfg.MaxBody),
+				)
+			}
+
+			level := zapcore.InfoLevel
+			switch {
+			case res.Status >= http.StatusInternalServerError:
+				level = zapcore.ErrorLevel
+			case res.Status >= http.StatusBadRequest:
+				level = zapcore.WarnLevel
+			}
+			L(c).Log(level, "request", fields...)
+			// the error is handled, returning it would have echo answer twice
+			return nil
+		}
+	}
+}
+
+// peek reads up to limit+1 bytes of a JSON request body and puts them back
+// in front of the rest, so the handler still sees the whole body.
+func peek(req *http.Request, limit int) []byte {
+	if req.Body == nil || !isJSON(req.Header.Get(echo.HeaderContentType)) {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	req.Body = readCloser{io.MultiReader(bytes.NewReader(b), req.Body), req.Body}
+	return b
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// capture keeps up to limit+1 bytes of what the handler writes.
+type capture struct {
+	http.ResponseWriter
+	limit int
+	buf   bytes.Buffer
+}
+
+func (w *capture) Write(b []byte) (int, error) {
+	if room := w.limit + 1 - w.buf.Len(); room > 0 {
+		w.buf.Write(b[:min(room, len(b))])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, echo.MIMEApplicationJSON)
+}
@@ -0,0 +1,131 @@
+//go:build unit
+
+package mlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var accessCfg = config.AccessLog{
+	Body:    true,
+	MaxBody: 1024,
+	Redact:  "email:email,name:partial,amount:full",
+}
+
+// access serves one request through Middleware and AccessLog and returns
+// the access log line.
+func access(t *testing.T, cfg config.AccessLog, h echo.HandlerFunc, req *http.Request) (observer.LoggedEntry, *httptest.ResponseRecorder) {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	e := echo.New()
+	e.Use(Middleware(zap.New(core)), AccessLog(cfg))
+	e.POST("/spenders/:id", h)
+	e.GET("/spenders/:id", h)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	entries := logs.FilterMessage("request").AllUntimed()
+	assert.Len(t, entries, 1)
+	return entries[0], rec
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("logs one line with the request and trace fields", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/spenders/7?email=hong@jot.ok", nil)
+		req.Header.Set(HeaderTraceparent, traceparent)
+
+		entry, _ := access(t, config.AccessLog{}, func(c echo.Context) error {
+			return c.String(http.StatusOK, "hello")
+		}, req)
+
+		fields := entry.ContextMap()
+		assert.Equal(t, zapcore.InfoLevel, entry.Level)
+		assert.Equal(t, "GET", fields["method"])
+		assert.Equal(t, "/spenders/7", fields["path"])
+		assert.Equal(t, "/spenders/:id", fields["route"])
+		assert.Equal(t, int64(200), fields["status"])
+		assert.Equal(t, int64(5), fields["bytes_out"])
+		assert.Equal(t, traceID, fields["trace-id"])
+		assert.Equal(t, parentID, fields["parent-id"])
+		assert.Contains(t, fields, "latency")
+		assert.NotContains(t, fields, "request_body")
+	})
+
+	t.Run("logs the status echo sent for an error", func(t *testing.T) {
+		entry, rec := access(t, config.AccessLog{}, func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusNotFound, "spender not found")
+		}, httptest.NewRequest(http.MethodGet, "/spenders/7", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, zapcore.WarnLevel, entry.Level)
+		assert.Equal(t, int64(404), entry.ContextMap()["status"])
+		assert.Contains(t, entry.ContextMap()["error"], "spender not found")
+	})
+
+	t.Run("hands an error to echo only once", func(t *testing.T) {
+		e := echo.New()
+		handled := 0
+		e.HTTPErrorHandler = func(err error, c echo.Context) {
+			handled++
+			e.DefaultHTTPErrorHandler(err, c)
+		}
+		e.Use(Middleware(zap.NewNop()), AccessLog(config.AccessLog{}))
+		e.GET("/spenders/:id", func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusNotFound, "spender not found")
+		})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/spenders/7", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, 1, handled)
+	})
+
+	t.Run("captures bodies with personal data masked", func(t *testing.T) {
+		body := `{"name": "HongJot", "email": "hong@jot.ok", "items": [{"amount": 100}], "note": "mail me at jot@hong.ok"}`
+		req := httptest.NewRequest(http.MethodPost, "/spenders/7", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		var got string
+		entry, _ := access(t, accessCfg, func(c echo.Context) error {
+			b, _ := io.ReadAll(c.Request().Body)
+			got = string(b)
+			return c.JSON(http.StatusCreated, map[string]any{"id": 1, "Email": "hong@jot.ok", "amounts": []int{1, 2}})
+		}, req)
+
+		assert.Equal(t, body, got)
+		assert.Equal(t, map[string]any{
+			"name":  "H***",
+			"email": "h***@jot.ok",
+			"items": []any{map[string]any{"amount": "******"}},
+			"note":  "mail me at j***@hong.ok",
+		}, entry.ContextMap()["request_body"])
+		res := entry.ContextMap()["response_body"].(map[string]any)
+		assert.Equal(t, "h***@jot.ok", res["Email"])
+		assert.NotContains(t, entry.ContextMap(), "hong@jot.ok")
+	})
+
+	t.Run("leaves out bodies it cannot mask", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/spenders/7", strings.NewReader(`{"name": "`+strings.Repeat("x", 2000)+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		entry, _ := access(t, accessCfg, func(c echo.Context) error {
+			return c.String(http.StatusOK, "hong@jot.ok")
+		}, req)
+
+		assert.Equal(t, "<omitted: larger than max_body>", entry.ContextMap()["request_body"])
+		assert.Equal(t, "<omitted: "+echo.MIMETextPlainCharsetUTF8+">", entry.ContextMap()["response_body"])
+	})
+}
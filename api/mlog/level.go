@@ -0,0 +1,221 @@
+package mlog
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels is the root log level plus overrides per package, all changeable
+// while the service runs. A package is matched by the last elements of its
+// import path, so "spender" and "api/spender" both name the spender package.
+type Levels struct {
+	root zap.AtomicLevel
+
+	mu       sync.RWMutex
+	packages map[string]zapcore.Level
+}
+
+func NewLevels(root zapcore.Level, packages map[string]zapcore.Level) *Levels {
+	l := &Levels{root: zap.NewAtomicLevelAt(root), packages: map[string]zapcore.Level{}}
+	for name, level := range packages {
+		l.packages[name] = level
+	}
+	return l
+}
+
+// New builds the service logger: JSON to stderr like zap.NewProduction, but
+// filtered by levels instead of a fixed level and without zap's own
+// sampling, which would drop errors too. Use Sample to thin out busy routes.
+func New(cfg config.Log) (*zap.Logger, *Levels, error) {
+	root, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	packages, err := cfg.PackageLevels()
+	if err != nil {
+		return nil, nil, err
+	}
+	levels := NewLevels(root, packages)
+
+	out := zapcore.Lock(os.Stderr)
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), out, zapcore.DebugLevel)
+	return zap.New(levels.Core(core), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(out)), levels, nil
+}
+
+// Core wraps core so it only writes entries enabled for the package that
+// logged them.
+func (l *Levels) Core(core zapcore.Core) zapcore.Core {
+	return &levelCore{Core: core, levels: l}
+}
+
+func (l *Levels) Level() zapcore.Level {
+	return l.root.Level()
+}
+
+func (l *Levels) SetLevel(level zapcore.Level) {
+	l.root.SetLevel(level)
+}
+
+// Packages returns a copy of the per package overrides.
+func (l *Levels) Packages() map[string]zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	packages := make(map[string]zapcore.Level, len(l.packages))
+	for name, level := range l.packages {
+		packages[name] = level
+	}
+	return packages
+}
+
+func (l *Levels) SetPackage(name string, level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.packages[name] = level
+}
+
+// ResetPackage drops the override, so the package follows the root level.
+func (l *Levels) ResetPackage(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.packages, name)
+}
+
+// min is the lowest level any package may log at.
+func (l *Levels) min() zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	min := l.root.Level()
+	for _, level := range l.packages {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+// enabled reports whether pkg, a full import path, logs at level. The
+// longest matching override wins.
+func (l *Levels) enabled(pkg string, level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	threshold, matched := l.root.Level(), ""
+	for name, override := range l.packages {
+		if (pkg == name || strings.HasSuffix(pkg, "/"+name)) && len(name) > len(matched) {
+			threshold, matched = override, name
+		}
+	}
+	return level >= threshold
+}
+
+// levelCore lets through everything some package may log and decides per
+// package in Write, the first point where zap knows the caller.
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return level >= c.levels.min()
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *levelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.levels.enabled(callerPackage(ent.Caller), ent.Level) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// callerPackage turns "github.com/x/api/spender.handler.Create" into
+// "github.com/x/api/spender".
+func callerPackage(caller zapcore.EntryCaller) string {
+	fn := caller.Function
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
+
+// LevelState is the body of the admin log level endpoint. In an update an
+// empty package level removes that override.
+type LevelState struct {
+	Level    string            `json:"level,omitempty"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+func (l *Levels) state() LevelState {
+	s := LevelState{Level: l.Level().String(), Packages: map[string]string{}}
+	for name, level := range l.Packages() {
+		s.Packages[name] = level.String()
+	}
+	return s
+}
+
+// Get is the admin endpoint reporting the current levels.
+func (l *Levels) Get(c echo.Context) error {
+	return c.JSON(http.StatusOK, l.state())
+}
+
+// Update is the admin endpoint changing levels. Nothing changes unless the
+// whole request is valid. Errors are echo's own, as this package sits below
+// the problem package that renders them.
+func (l *Levels) Update(c echo.Context) error {
+	var req LevelState
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	var root zapcore.Level
+	if req.Level != "" {
+		var err error
+		if root, err = zapcore.ParseLevel(req.Level); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown level "+req.Level)
+		}
+	}
+	packages := map[string]zapcore.Level{}
+	for name, value := range req.Packages {
+		if value == "" {
+			continue
+		}
+		level, err := zapcore.ParseLevel(value)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown level "+value+" for package "+name)
+		}
+		packages[name] = level
+	}
+
+	if req.Level != "" {
+		l.SetLevel(root)
+	}
+	for name, value := range req.Packages {
+		if value == "" {
+			l.ResetPackage(name)
+		} else {
+			l.SetPackage(name, packages[name])
+		}
+	}
+
+	L(c).Info("log levels changed", zap.String("level", l.Level().String()), zap.Any("packages", l.state().Packages))
+	return c.JSON(http.StatusOK, l.state())
+}
@@ -0,0 +1,144 @@
+//go:build unit
+
+package mlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLevels(t *testing.T) {
+	newLogger := func(levels *Levels) (*zap.Logger, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		return zap.New(levels.Core(core), zap.AddCaller()), logs
+	}
+
+	t.Run("should follow the root level", func(t *testing.T) {
+		levels := NewLevels(zapcore.InfoLevel, nil)
+		logger, logs := newLogger(levels)
+
+		logger.Debug("hidden")
+		levels.SetLevel(zapcore.DebugLevel)
+		logger.Debug("shown")
+
+		assert.Equal(t, []string{"shown"}, messages(logs))
+	})
+
+	t.Run("should let a package log below the root level", func(t *testing.T) {
+		levels := NewLevels(zapcore.WarnLevel, map[string]zapcore.Level{"spender": zapcore.ErrorLevel})
+		logger, logs := newLogger(levels)
+
+		logger.Info("hidden")
+		levels.SetPackage("api/mlog", zapcore.DebugLevel)
+		logger.Debug("shown")
+		levels.ResetPackage("api/mlog")
+		logger.Debug("hidden again")
+
+		assert.Equal(t, []string{"shown"}, messages(logs))
+		assert.Equal(t, map[string]zapcore.Level{"spender": zapcore.ErrorLevel}, levels.Packages())
+	})
+
+	t.Run("should let a package log less than the root level", func(t *testing.T) {
+		levels := NewLevels(zapcore.DebugLevel, map[string]zapcore.Level{"mlog": zapcore.ErrorLevel})
+		logger, logs := newLogger(levels)
+
+		logger.Warn("hidden")
+		logger.Error("shown")
+
+		assert.Equal(t, []string{"shown"}, messages(logs))
+	})
+
+	t.Run("should find the package of a caller", func(t *testing.T) {
+		for fn, want := range map[string]string{
+			"github.com/x/api/spender.handler.Create":    "github.com/x/api/spender",
+			"github.com/x/api/spender.(*handler).Create": "github.com/x/api/spender",
+			"main.main": "main",
+			"":          "",
+		} {
+			assert.Equal(t, want, callerPackage(zapcore.EntryCaller{Function: fn}), fn)
+		}
+	})
+}
+
+func TestLevelsAdmin(t *testing.T) {
+	serve := func(levels *Levels, method, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.Use(Middleware(zap.NewNop()))
+		e.GET("/log/level", levels.Get)
+		e.PUT("/log/level", levels.Update)
+
+		req := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should report the current levels", func(t *testing.T) {
+		levels := NewLevels(zapcore.InfoLevel, map[string]zapcore.Level{"spender": zapcore.DebugLevel})
+
+		rec := serve(levels, http.MethodGet, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"level": "info", "packages": {"spender": "debug"}}`, rec.Body.String())
+	})
+
+	t.Run("should change the root and package levels", func(t *testing.T) {
+		levels := NewLevels(zapcore.InfoLevel, map[string]zapcore.Level{"spender": zapcore.DebugLevel})
+
+		rec := serve(levels, http.MethodPut, `{"level": "warn", "packages": {"spender": "", "eslip": "debug"}}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"level": "warn", "packages": {"eslip": "debug"}}`, rec.Body.String())
+		assert.Equal(t, zapcore.WarnLevel, levels.Level())
+	})
+
+	t.Run("should change nothing when a level is unknown", func(t *testing.T) {
+		levels := NewLevels(zapcore.InfoLevel, nil)
+
+		rec := serve(levels, http.MethodPut, `{"level": "warn", "packages": {"eslip": "loud"}}`)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "unknown level loud for package eslip")
+		assert.Equal(t, zapcore.InfoLevel, levels.Level())
+		assert.Empty(t, levels.Packages())
+	})
+}
+
+func TestSample(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	e := echo.New()
+	e.Use(Middleware(zap.New(core)), Sample(config.Log{Sample: "GET /spenders=3"}))
+	h := func(c echo.Context) error {
+		L(c).Info("handled")
+		L(c).Warn("slow")
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/spenders", h)
+	e.GET("/spenders/:id", h)
+
+	for i := 0; i < 6; i++ {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/spenders", nil))
+	}
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/spenders/7", nil))
+
+	assert.Equal(t, 3, logs.FilterMessage("handled").Len(), "1 in 3 of the 6 sampled plus the unsampled route")
+	assert.Equal(t, 7, logs.FilterMessage("slow").Len())
+}
+
+func messages(logs *observer.ObservedLogs) []string {
+	var out []string
+	for _, entry := range logs.AllUntimed() {
+		out = append(out, entry.Message)
+	}
+	return out
+}
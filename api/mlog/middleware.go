@@ -1,7 +1,6 @@
 package mlog
 
 import (
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
@@ -14,18 +13,25 @@ func Middleware(logger *zap.Logger) echo.MiddlewareFunc {
 
 func logMiddleware(next echo.HandlerFunc, logger *zap.Logger) func(c echo.Context) error {
 	return func(c echo.Context) error {
-		l := logParentID(c, logger)
-		c.Set(key, l)
+		req := c.Request()
+		t := traceFrom(req.Context(), req.Header)
+
+		c.SetRequest(req.WithContext(WithTrace(req.Context(), t)))
+
+		h := c.Response().Header()
+		h.Set(HeaderTraceparent, t.Traceparent())
+		if t.State != "" {
+			h.Set(HeaderTracestate, t.State)
+		}
+		h.Set(HeaderTraceID, t.TraceID)
+
+		c.Set(key, logTrace(t, logger))
 		return next(c)
 	}
 }
 
-func logParentID(c echo.Context, logger *zap.Logger) *zap.Logger {
-	xParent := c.Request().Header.Get("X-Parent-ID")
-	if xParent == "" {
-		xParent = uuid.NewString()
-	}
-	xSpan := uuid.NewString()
-	return logger.With(zap.String("parent-id", xParent),
-		zap.String("span-id", xSpan))
+func logTrace(t Trace, logger *zap.Logger) *zap.Logger {
+	return logger.With(zap.String("trace-id", t.TraceID),
+		zap.String("parent-id", t.ParentID),
+		zap.String("span-id", t.SpanID))
 }
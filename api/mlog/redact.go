@@ -0,0 +1,96 @@
+package mlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const mask = "******"
+
+var emailPattern = regexp.MustCompile(`[^\s@"<>,;:]+@[^\s@"<>,;:]+\.[^\s@"<>,;:]+`)
+
+// redactor maps lower-cased JSON field names to a mode of
+// config.RedactModes.
+type redactor map[string]string
+
+// field logs a body as JSON with its fields masked. Bodies that are not
+// JSON or are longer than limit are left out, since they cannot be masked.
+func (r redactor) field(key, contentType string, b []byte, limit int) zap.Field {
+	switch {
+	case len(b) == 0:
+		return zap.Skip()
+	case !isJSON(contentType):
+		return zap.String(key, "<omitted: "+contentType+">")
+	case len(b) > limit:
+		return zap.String(key, "<omitted: larger than max_body>")
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return zap.String(key, "<omitted: invalid json>")
+	}
+	return zap.Any(key, r.value("", v))
+}
+
+// value masks v if its field has a rule. Array elements take the rule of
+// the field holding the array. Emails are masked in any other string.
+func (r redactor) value(field string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = r.value(k, x)
+		}
+		return v
+	case []any:
+		for i, x := range v {
+			v[i] = r.value(field, x)
+		}
+		return v
+	}
+
+	if mode, ok := r[strings.ToLower(field)]; ok {
+		return maskValue(mode, v)
+	}
+	if s, ok := v.(string); ok {
+		return emailPattern.ReplaceAllStringFunc(s, maskEmail)
+	}
+	return v
+}
+
+func maskValue(mode string, v any) any {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return mask
+	}
+
+	switch mode {
+	case "email":
+		if strings.Contains(s, "@") {
+			return maskEmail(s)
+		}
+		return maskPartial(s)
+	case "partial":
+		return maskPartial(s)
+	default:
+		return mask
+	}
+}
+
+// maskPartial keeps the first letter, e.g. "HongJot" becomes "H***".
+func maskPartial(s string) string {
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r) + "***"
+}
+
+// maskEmail keeps the first letter and the domain, e.g. "h***@jot.ok".
+func maskEmail(s string) string {
+	local, domain, _ := strings.Cut(s, "@")
+	return maskPartial(local) + "@" + domain
+}
@@ -0,0 +1,38 @@
+package mlog
+
+import (
+	"sync/atomic"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sample keeps the info and debug logs of only one in N requests on the
+// routes cfg.Sample names; the rest still log warnings and errors, so a
+// failing request is never lost. It has to run after Middleware and before
+// AccessLog.
+func Sample(cfg config.Log) echo.MiddlewareFunc {
+	// the rules were checked when the config was loaded
+	rules, _ := cfg.SampleRules()
+	counters := make(map[string]*atomic.Uint64, len(rules))
+	for route := range rules {
+		counters[route] = new(atomic.Uint64)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route := c.Request().Method + " " + c.Path()
+			n, ok := rules[route]
+			if !ok || (counters[route].Add(1)-1)%uint64(n) == 0 {
+				return next(c)
+			}
+
+			if logger := L(c); logger.Core().Enabled(zapcore.WarnLevel) {
+				c.Set(key, logger.WithOptions(zap.IncreaseLevel(zapcore.WarnLevel)))
+			}
+			return next(c)
+		}
+	}
+}
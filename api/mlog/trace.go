@@ -0,0 +1,147 @@
+package mlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+	HeaderParentID    = "X-Parent-ID"
+	HeaderTraceID     = "X-Trace-ID"
+)
+
+// Trace is the W3C trace context of a request. SpanID identifies this
+// request; ParentID is the caller's span and is empty when the trace
+// started here. State is the caller's tracestate, passed on unchanged.
+type Trace struct {
+	TraceID  string
+	ParentID string
+	SpanID   string
+	Flags    string
+	State    string
+}
+
+// Traceparent is the header value naming this request as the parent.
+func (t Trace) Traceparent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// traceFrom continues the caller's trace from traceparent, or from the
+// older X-Parent-ID header, and starts a new one otherwise. When OpenTelemetry
+// already started a span for the request its ids are used, so logs and
+// exported spans share the same trace and span ids.
+func traceFrom(ctx context.Context, h http.Header) Trace {
+	t := Trace{SpanID: randomHex(8)}
+
+	if traceID, parentID, flags, ok := parseTraceparent(h.Get(HeaderTraceparent)); ok {
+		t.TraceID, t.ParentID, t.Flags = traceID, parentID, flags
+		t.State = h.Get(HeaderTracestate)
+	} else {
+		t.TraceID, t.Flags = randomHex(16), "01"
+		t.ParentID = h.Get(HeaderParentID)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		t.TraceID, t.SpanID = sc.TraceID().String(), sc.SpanID().String()
+		t.Flags = sc.TraceFlags().String()
+	}
+	return t
+}
+
+// parseTraceparent accepts version 00 headers and, as the spec asks, the
+// first four fields of later versions.
+func parseTraceparent(s string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	switch {
+	case !isHex(version, 2) || version == "ff":
+		return "", "", "", false
+	case version == "00" && len(parts) != 4:
+		return "", "", "", false
+	case !isHex(traceID, 32) || traceID == strings.Repeat("0", 32):
+		return "", "", "", false
+	case !isHex(parentID, 16) || parentID == strings.Repeat("0", 16):
+		return "", "", "", false
+	case !isHex(flags, 2):
+		return "", "", "", false
+	}
+	return traceID, parentID, flags, true
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace the middleware put in ctx.
+func TraceFrom(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(traceKey{}).(Trace)
+	return t, ok
+}
+
+// Inject sets the headers that make the request in ctx the parent of an
+// outbound call. X-Parent-ID is kept for services not on trace context yet.
+func Inject(ctx context.Context, h http.Header) {
+	t, ok := TraceFrom(ctx)
+	if !ok {
+		return
+	}
+	h.Set(HeaderTraceparent, t.Traceparent())
+	if t.State != "" {
+		h.Set(HeaderTracestate, t.State)
+	}
+	h.Set(HeaderParentID, t.SpanID)
+}
+
+// Transport wraps base so every request carries the trace in its context.
+// A nil base means http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{base}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := TraceFrom(req.Context()); !ok {
+		return rt.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	Inject(req.Context(), req.Header)
+	return rt.base.RoundTrip(req)
+}
@@ -0,0 +1,149 @@
+//go:build unit
+
+package mlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID    = "00f067aa0ba902b7"
+	traceparent = "00-" + traceID + "-" + parentID + "-01"
+)
+
+// serve runs the middleware for a request with headers h and returns the
+// trace the handler saw and the response.
+func serve(h map[string]string) (Trace, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Use(Middleware(zap.NewNop()))
+
+	var got Trace
+	e.GET("/", func(c echo.Context) error {
+		got, _ = TraceFrom(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range h {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return got, rec
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	t.Run("continues the trace from traceparent", func(t *testing.T) {
+		got, rec := serve(map[string]string{
+			HeaderTraceparent: traceparent,
+			HeaderTracestate:  "vendor=abc",
+			HeaderParentID:    "ignored",
+		})
+
+		assert.Equal(t, traceID, got.TraceID)
+		assert.Equal(t, parentID, got.ParentID)
+		assert.Len(t, got.SpanID, 16)
+		assert.NotEqual(t, parentID, got.SpanID)
+		assert.Equal(t, "01", got.Flags)
+		assert.Equal(t, "vendor=abc", got.State)
+
+		assert.Equal(t, "00-"+traceID+"-"+got.SpanID+"-01", rec.Header().Get(HeaderTraceparent))
+		assert.Equal(t, "vendor=abc", rec.Header().Get(HeaderTracestate))
+		assert.Equal(t, traceID, rec.Header().Get(HeaderTraceID))
+	})
+
+	t.Run("falls back to X-Parent-ID", func(t *testing.T) {
+		got, rec := serve(map[string]string{HeaderParentID: "legacy-parent"})
+
+		assert.Equal(t, "legacy-parent", got.ParentID)
+		assert.Len(t, got.TraceID, 32)
+		assert.Equal(t, got.Traceparent(), rec.Header().Get(HeaderTraceparent))
+		assert.Empty(t, rec.Header().Get(HeaderTracestate))
+	})
+
+	t.Run("starts a new trace without headers", func(t *testing.T) {
+		got, _ := serve(nil)
+
+		assert.Len(t, got.TraceID, 32)
+		assert.Empty(t, got.ParentID)
+	})
+
+	t.Run("ignores a malformed traceparent", func(t *testing.T) {
+		for _, h := range []string{
+			"garbage",
+			"00-" + traceID + "-" + parentID,
+			"00-" + traceID + "-" + parentID + "-01-extra",
+			"ff-" + traceID + "-" + parentID + "-01",
+			"00-00000000000000000000000000000000-" + parentID + "-01",
+			"00-" + traceID + "-0000000000000000-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + parentID + "-01",
+		} {
+			got, _ := serve(map[string]string{HeaderTraceparent: h})
+
+			assert.NotEqual(t, traceID, got.TraceID, h)
+		}
+	})
+
+	t.Run("uses the ids of an opentelemetry span", func(t *testing.T) {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:     trace.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+			TraceFlags: trace.FlagsSampled,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderTraceparent, traceparent)
+
+		got := traceFrom(trace.ContextWithSpanContext(req.Context(), sc), req.Header)
+
+		assert.Equal(t, traceID, got.TraceID)
+		assert.Equal(t, parentID, got.ParentID)
+		assert.Equal(t, "b7ad6b7169203331", got.SpanID)
+		assert.Equal(t, "01", got.Flags)
+	})
+
+	t.Run("accepts fields added by a later version", func(t *testing.T) {
+		got, _ := serve(map[string]string{HeaderTraceparent: "01-" + traceID + "-" + parentID + "-01-future"})
+
+		assert.Equal(t, traceID, got.TraceID)
+	})
+}
+
+func TestTransport(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	t.Run("carries the trace forward", func(t *testing.T) {
+		trace := Trace{TraceID: traceID, ParentID: parentID, SpanID: "b7ad6b7169203331", Flags: "01", State: "vendor=abc"}
+		req, _ := http.NewRequestWithContext(WithTrace(context.Background(), trace), http.MethodGet, srv.URL, nil)
+
+		resp, err := client.Do(req)
+
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "00-"+traceID+"-b7ad6b7169203331-01", got.Get(HeaderTraceparent))
+		assert.Equal(t, "vendor=abc", got.Get(HeaderTracestate))
+		assert.Equal(t, "b7ad6b7169203331", got.Get(HeaderParentID))
+		assert.Empty(t, req.Header.Get(HeaderTraceparent))
+	})
+
+	t.Run("sends nothing without a trace", func(t *testing.T) {
+		resp, err := client.Get(srv.URL)
+
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Empty(t, got.Get(HeaderTraceparent))
+	})
+}
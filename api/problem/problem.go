@@ -0,0 +1,154 @@
+// Package problem turns errors into RFC 7807 problem details. Handlers return
+// an *Error for anything the client should act on, and Handler, installed as
+// echo's HTTPErrorHandler, writes it as application/problem+json. Any other
+// error is a 500 whose text stays in the log, so SQL never reaches clients.
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// CodeInternal is the code of every error a handler did not describe.
+const CodeInternal = "internal"
+
+// Error is a failure the client may see. Code is stable for clients to branch
+// on, Detail is for people, and Err is the cause, logged but never sent.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap records err as the cause of e and returns e.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// New is an error with any other status, such as 422 or 503.
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func NotFound(code, detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: code, Detail: detail}
+}
+
+// Validation is a request that is malformed or breaks a rule of its own,
+// such as an id that is not a number.
+func Validation(code, detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: code, Detail: detail}
+}
+
+func Conflict(code, detail string) *Error {
+	return &Error{Status: http.StatusConflict, Code: code, Detail: detail}
+}
+
+func Forbidden(code, detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: code, Detail: detail}
+}
+
+func Unauthorized(code, detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: code, Detail: detail}
+}
+
+// Internal is a 500 for a failure the handler has already logged, such as a
+// query that failed. Handler logs the 500s it is given as plain errors.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: "internal server error", Err: err}
+}
+
+// Problem is the application/problem+json body. TraceID and ParentID are
+// the ones the request's log lines carry.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	TraceID  string `json:"trace_id,omitempty"`
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// From describes err for the client. echo's own errors, such as an unknown
+// route or a body that does not bind, keep their status and message.
+func From(err error) *Error {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) && he.Code < http.StatusInternalServerError {
+		detail, ok := he.Message.(string)
+		if !ok {
+			detail = http.StatusText(he.Code)
+		}
+		return &Error{Status: he.Code, Code: code(he.Code), Detail: detail, Err: err}
+	}
+
+	return Internal(err)
+}
+
+// code names a status the way Error codes are written: 404 is "not_found".
+func code(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// Handler is echo's HTTPErrorHandler. It leaves responses a handler already
+// started alone, and logs the errors no handler described.
+func Handler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	e := From(err)
+	logger := mlog.L(c)
+	var pe *Error
+	if e.Status >= http.StatusInternalServerError && !errors.As(err, &pe) {
+		logger.Error("request failed", zap.Error(err))
+	}
+
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Instance: c.Request().URL.Path,
+		Code:     e.Code,
+	}
+	if t, ok := mlog.TraceFrom(c.Request().Context()); ok {
+		p.TraceID, p.ParentID = t.TraceID, t.ParentID
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(e.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		err = c.JSON(e.Status, p)
+	}
+	if err != nil {
+		logger.Error("writing the problem failed", zap.Error(err))
+	}
+}
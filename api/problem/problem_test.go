@@ -0,0 +1,148 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHandler(t *testing.T) {
+	serve := func(method string, h echo.HandlerFunc, header http.Header) *httptest.ResponseRecorder {
+		e := echo.New()
+		defer e.Close()
+		e.HTTPErrorHandler = Handler
+		e.Use(mlog.Middleware(zap.NewNop()))
+		e.Add(method, "/api/v1/spenders/:id", h)
+
+		req := httptest.NewRequest(method, "/api/v1/spenders/7", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should render a typed error as problem+json", func(t *testing.T) {
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			return NotFound("spender_not_found", "spender not found")
+		}, nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Not Found",
+			"status": 404,
+			"detail": "spender not found",
+			"instance": "/api/v1/spenders/7",
+			"code": "spender_not_found",
+			"trace_id": "`+rec.Header().Get(mlog.HeaderTraceID)+`"
+		}`, rec.Body.String())
+	})
+
+	t.Run("should carry the caller's trace and parent id", func(t *testing.T) {
+		traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			return Validation("invalid_id", "id must be an integer")
+		}, http.Header{"Traceparent": {traceparent}})
+
+		var p Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		assert.Equal(t, http.StatusBadRequest, p.Status)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", p.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", p.ParentID)
+	})
+
+	t.Run("should not leak the cause of an internal error", func(t *testing.T) {
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			return fmt.Errorf("query: %w", errors.New(`pq: relation "spender" does not exist`))
+		}, nil)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"internal"`)
+		assert.NotContains(t, rec.Body.String(), "relation")
+		assert.NotContains(t, rec.Body.String(), "pq:")
+	})
+
+	t.Run("should not leak the cause of a wrapped error", func(t *testing.T) {
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			return Conflict("spender_email_key", "email is already used by another spender").Wrap(errors.New("pq: duplicate key"))
+		}, nil)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "duplicate key")
+	})
+
+	t.Run("should keep the status and message of echo's errors", func(t *testing.T) {
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type")
+		}, nil)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"unsupported_media_type"`)
+		assert.Contains(t, rec.Body.String(), `"detail":"unsupported media type"`)
+	})
+
+	t.Run("should answer an unknown route as not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+		e.HTTPErrorHandler = Handler
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"not_found"`)
+	})
+
+	t.Run("should leave a response the handler started alone", func(t *testing.T) {
+		rec := serve(http.MethodGet, func(c echo.Context) error {
+			c.String(http.StatusOK, "partial")
+			return errors.New("client went away")
+		}, nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "partial", rec.Body.String())
+	})
+
+	t.Run("should send no body for HEAD", func(t *testing.T) {
+		rec := serve(http.MethodHead, func(c echo.Context) error {
+			return Forbidden("access_denied", "access denied")
+		}, nil)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
+
+func TestFrom(t *testing.T) {
+	t.Run("should find a typed error through wrapping", func(t *testing.T) {
+		err := fmt.Errorf("get: %w", NotFound("slip_not_found", "slip not found"))
+
+		assert.Equal(t, http.StatusNotFound, From(err).Status)
+		assert.Equal(t, "slip_not_found", From(err).Code)
+	})
+
+	t.Run("should hide echo's server errors", func(t *testing.T) {
+		e := From(echo.NewHTTPError(http.StatusServiceUnavailable, "db is down"))
+
+		assert.Equal(t, http.StatusInternalServerError, e.Status)
+		assert.Equal(t, CodeInternal, e.Code)
+	})
+
+	t.Run("should unwrap to the cause", func(t *testing.T) {
+		e := Validation("invalid_body", "request body is invalid").Wrap(assert.AnError)
+
+		assert.ErrorIs(t, e, assert.AnError)
+	})
+}
@@ -0,0 +1,85 @@
+package spender
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+)
+
+// MemoryStore keeps spenders in a map, for tests and running without a
+// database. Like the schema it refuses an email another spender already
+// uses, ignoring case.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	rows   map[int64]Spender
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rows: map[int64]Spender{}}
+}
+
+// emailTaken must be called holding mu.
+func (s *MemoryStore) emailTaken(sp Spender) error {
+	for _, other := range s.rows {
+		if other.ID != sp.ID && strings.EqualFold(other.Email, sp.Email) {
+			return database.Violation(http.StatusConflict, "spender_email_key")
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Spender, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sps []Spender
+	for _, sp := range s.rows {
+		sps = append(sps, sp)
+	}
+	sort.Slice(sps, func(i, j int) bool { return sps[i].ID < sps[j].ID })
+	return sps, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id int64) (Spender, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sp, ok := s.rows[id]
+	if !ok {
+		return Spender{}, ErrNotFound
+	}
+	return sp, nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, sp Spender) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp.ID = 0
+	if err := s.emailTaken(sp); err != nil {
+		return 0, err
+	}
+	s.nextID++
+	sp.ID = s.nextID
+	s.rows[sp.ID] = sp
+	return sp.ID, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, sp Spender) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rows[sp.ID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.emailTaken(sp); err != nil {
+		return err
+	}
+	s.rows[sp.ID] = sp
+	return nil
+}
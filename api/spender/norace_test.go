@@ -0,0 +1,5 @@
+//go:build !race
+
+package spender
+
+const raceEnabled = false
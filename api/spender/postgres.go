@@ -0,0 +1,84 @@
+package spender
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const (
+	cStmt = `INSERT INTO spender (name, email) VALUES ($1, $2) RETURNING id;`
+	uStmt = `UPDATE spender SET name=$1, email=$2 WHERE id=$3`
+)
+
+// PostgresStore keeps spenders in the spender table. Its queries stay within
+// the SQL ramsql understands, so the embedded backend uses it too.
+type PostgresStore struct {
+	db     *sql.DB
+	ramsql bool
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// NewRamSQLStore returns a PostgresStore for the embedded ramsql database,
+// working around where its behaviour differs from Postgres.
+func NewRamSQLStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, ramsql: true}
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]Spender, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, email FROM spender ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sps []Spender
+	for rows.Next() {
+		var sp Spender
+		if err := rows.Scan(&sp.ID, &sp.Name, &sp.Email); err != nil {
+			return nil, err
+		}
+		sps = append(sps, sp)
+	}
+	return sps, rows.Err()
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id int64) (Spender, error) {
+	var sp Spender
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, email FROM spender WHERE id=$1`, id).Scan(&sp.ID, &sp.Name, &sp.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sp, ErrNotFound
+	}
+	return sp, err
+}
+
+func (s *PostgresStore) Create(ctx context.Context, sp Spender) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&id)
+	return id, err
+}
+
+func (s *PostgresStore) Update(ctx context.Context, sp Spender) error {
+	res, err := s.db.ExecContext(ctx, uStmt, sp.Name, sp.Email, sp.ID)
+	if err != nil {
+		// ramsql fails an UPDATE that matches no row instead of reporting
+		// none affected
+		if s.ramsql {
+			if _, getErr := s.Get(ctx, sp.ID); errors.Is(getErr, ErrNotFound) {
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
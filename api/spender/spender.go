@@ -1,12 +1,14 @@
 package spender
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
-	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/constanst"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
@@ -20,37 +22,34 @@ type Spender struct {
 }
 
 type handler struct {
-	flag config.FeatureFlag
-	db   *sql.DB
+	spenders     SpenderStore
+	transactions transaction.TransactionStore
 }
 
-func New(cfg config.FeatureFlag, db *sql.DB) *handler {
-	return &handler{cfg, db}
+func New(spenders SpenderStore, transactions transaction.TransactionStore) *handler {
+	return &handler{spenders, transactions}
 }
 
-const (
-	cStmt = `INSERT INTO spender (name, email) VALUES ($1, $2) RETURNING id;`
-)
-
 func (h handler) Create(c echo.Context) error {
-	if !h.flag.EnableCreateSpender {
-		return c.JSON(http.StatusForbidden, "create new spender feature is disabled")
-	}
-
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
+	ctx, cancel := database.Context(c)
+	defer cancel()
 	var sp Spender
 	err := c.Bind(&sp)
 	if err != nil {
 		logger.Error(constanst.NonIntError, zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
 	}
 
-	var lastInsertId int64
-	err = h.db.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&lastInsertId)
+	lastInsertId, err := h.spenders.Create(ctx, sp)
 	if err != nil {
+		if ce, ok := database.Constraint(err); ok {
+			logger.Warn(ce.Message, zap.String("constraint", ce.Constraint), zap.Error(err))
+			return ce.Problem()
+		}
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	logger.Info("create successfully", zap.Int64("id", lastInsertId))
@@ -60,24 +59,14 @@ func (h handler) Create(c echo.Context) error {
 
 func (h handler) GetAll(c echo.Context) error {
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	rows, err := h.db.QueryContext(ctx, `SELECT id, name, email FROM spender`)
+	sps, err := h.spenders.List(ctx)
 	if err != nil {
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
-	}
-	defer rows.Close()
-
-	var sps []Spender
-	for rows.Next() {
-		var sp Spender
-		err := rows.Scan(&sp.ID, &sp.Name, &sp.Email)
-		if err != nil {
-			logger.Error(constanst.ScanError, zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
-		}
-		sps = append(sps, sp)
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	return c.JSON(http.StatusOK, sps)
@@ -85,90 +74,81 @@ func (h handler) GetAll(c echo.Context) error {
 
 func (h handler) Get(c echo.Context) error {
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	id := c.Param("id")
-
-	if _, err := strconv.Atoi(id); err != nil {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
 		logger.Error(constanst.NonIntError)
-		return c.JSON(http.StatusBadRequest, constanst.NonIntError)
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
-	row := h.db.QueryRowContext(ctx, `SELECT id, name, email FROM spender WHERE id=$1`, id)
-	if row.Err() != nil {
-		logger.Error(constanst.QueryError, zap.Error(row.Err()))
-		return c.JSON(http.StatusNotFound, row.Err())
+	sp, err := h.spenders.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return problem.NotFound("spender_not_found", "spender not found")
 	}
-
-	var sp Spender
-	err := row.Scan(&sp.ID, &sp.Name, &sp.Email)
 	if err != nil {
-		logger.Error(constanst.ScanError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		logger.Error(constanst.QueryError, zap.Error(err))
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	return c.JSON(http.StatusOK, sp)
 }
 
 func (h handler) Update(c echo.Context) error {
-	if !h.flag.EnableUpdateSpender {
-		return c.JSON(http.StatusForbidden, "update spender feature is disabled")
-	}
-
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
-
-	id := c.Param("id")
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	if _, err := strconv.Atoi(id); err != nil {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
 		logger.Error(constanst.NonIntError)
-		return c.JSON(http.StatusBadRequest, constanst.NonIntError)
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
 	var sp Spender
-	err := c.Bind(&sp)
+	err = c.Bind(&sp)
 	if err != nil {
 		logger.Error(constanst.BadRequestBody, zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
 	}
+	sp.ID = id
 
-	_, err = h.db.ExecContext(ctx, `UPDATE spender SET name=$1, email=$2 WHERE id=$3`, sp.Name, sp.Email, id)
+	err = h.spenders.Update(ctx, sp)
+	if errors.Is(err, ErrNotFound) {
+		return problem.NotFound("spender_not_found", "spender not found")
+	}
 	if err != nil {
+		if ce, ok := database.Constraint(err); ok {
+			logger.Warn(ce.Message, zap.String("constraint", ce.Constraint), zap.Error(err))
+			return ce.Problem()
+		}
 		logger.Error("update error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
-	logger.Info("update successfully", zap.String("id", id))
+	logger.Info("update successfully", zap.Int64("id", id))
 	return c.JSON(http.StatusOK, "update successfully")
 }
 
 func (h handler) GetTransactions(c echo.Context) error {
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
-
-	id := c.Param("id")
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	if _, err := strconv.Atoi(id); err != nil {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
 		logger.Error(constanst.NonIntError)
-		return c.JSON(http.StatusBadRequest, constanst.NonIntError)
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
-	rows, err := h.db.QueryContext(ctx, `SELECT id, spender_id, date, amount, category, transaction_type, note, image_url FROM transaction WHERE spender_id=$1`, id)
+	ts, err := h.transactions.ListBySpender(ctx, id)
 	if err != nil {
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
-	}
-	defer rows.Close()
-
-	var ts []transaction.Transaction
-	for rows.Next() {
-		var t transaction.Transaction
-		err := rows.Scan(&t.ID, &t.SpenderID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.Note, &t.ImageUrl)
-		if err != nil {
-			logger.Error(constanst.ScanError, zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
-		}
-		ts = append(ts, t)
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	var totalIncome, totalExpenses, currentBalance float32
@@ -204,43 +184,27 @@ func (h handler) GetTransactions(c echo.Context) error {
 func (h handler) GetSummary(c echo.Context) error {
 
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
-
-	id := c.Param("id")
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	if _, err := strconv.Atoi(id); err != nil {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
 		logger.Error(constanst.NonIntError)
-		return c.JSON(http.StatusBadRequest, constanst.NonIntError)
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
-	rows, err := h.db.QueryContext(ctx, `SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`, id)
+	sum, err := h.transactions.Summary(ctx, id)
 	if err != nil {
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
-	defer rows.Close()
-
-	var totalIncome, totalExpenses, currentBalance float64
-	for rows.Next() {
-		var amount float64
-		var transactionType string
-		err := rows.Scan(&amount, &transactionType)
-		if err != nil {
-			logger.Error(constanst.ScanError, zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
-		}
-		if transactionType == "income" {
-			totalIncome += amount
-		} else {
-			totalExpenses += amount
-		}
-	}
-	currentBalance = totalIncome - totalExpenses
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"summary": map[string]float64{
-			"total_income":    totalIncome,
-			"total_expenses":  totalExpenses,
-			"current_balance": currentBalance,
+			"total_income":    sum.TotalIncome,
+			"total_expenses":  sum.TotalExpenses,
+			"current_balance": sum.CurrentBalance,
 		},
 	})
 }
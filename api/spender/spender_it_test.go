@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
 	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq"
@@ -25,7 +26,7 @@ func TestCreateSpenderIT(t *testing.T) {
 		migration.ApplyMigrations(sql)
 		defer migration.RollbackMigrations(sql)
 
-		h := New(config.FeatureFlag{EnableCreateSpender: true}, sql)
+		h := New(NewPostgresStore(sql), transaction.NewPostgresStore(sql))
 		e := echo.New()
 		defer e.Close()
 
@@ -52,7 +53,7 @@ func TestGetAllSpenderIT(t *testing.T) {
 		migration.ApplyMigrations(sql)
 		defer migration.RollbackMigrations(sql)
 
-		h := New(config.FeatureFlag{}, sql)
+		h := New(NewPostgresStore(sql), transaction.NewPostgresStore(sql))
 		e := echo.New()
 		defer e.Close()
 
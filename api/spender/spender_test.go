@@ -1,6 +1,7 @@
 package spender
 
 import (
+	"database/sql"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,14 +9,20 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
+func newHandler(db *sql.DB) *handler {
+	return New(NewPostgresStore(db), transaction.NewPostgresStore(db))
+}
+
 func TestCreateSpender(t *testing.T) {
 
-	t.Run("create spender succesfully when feature toggle is enable", func(t *testing.T) {
+	t.Run("create spender succesfully", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
@@ -29,9 +36,8 @@ func TestCreateSpender(t *testing.T) {
 
 		row := sqlmock.NewRows([]string{"id"}).AddRow(1)
 		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnRows(row)
-		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
-		h := New(cfg, db)
+		h := newHandler(db)
 		err := h.Create(c)
 
 		assert.NoError(t, err)
@@ -39,43 +45,45 @@ func TestCreateSpender(t *testing.T) {
 		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok"}`, rec.Body.String())
 	})
 
-	t.Run("create spender failed when feature toggle is disable", func(t *testing.T) {
+	t.Run("create spender failed when bad request body", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
-		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "HongJot", "email": "hong@jot.ok"}`))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{ bad request body }`))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		cfg := config.FeatureFlag{EnableCreateSpender: false}
-
-		h := New(cfg, nil)
+		h := newHandler(nil)
 		err := h.Create(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
+		assert.ErrorContains(t, err, "invalid character")
 	})
 
-	t.Run("create spender failed when bad request body", func(t *testing.T) {
+	t.Run("create spender failed on database", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
-		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{ bad request body }`))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "HongJot", "email": "hong@jot.ok"}`))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
-		h := New(cfg, nil)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(assert.AnError)
+
+		h := newHandler(db)
 		err := h.Create(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Contains(t, rec.Body.String(), "invalid character")
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 
-	t.Run("create spender failed on database (feature toggle is enable) ", func(t *testing.T) {
+	t.Run("create spender conflicts when email is taken", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
@@ -87,14 +95,14 @@ func TestCreateSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(assert.AnError)
-		cfg := config.FeatureFlag{EnableCreateSpender: true}
+		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(&pq.Error{Code: "23505", Constraint: "spender_email_key"})
 
-		h := New(cfg, db)
+		h := newHandler(db)
 		err := h.Create(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusConflict, problem.From(err).Status)
+		assert.ErrorContains(t, err, "email is already used")
 	})
 }
 
@@ -113,9 +121,9 @@ func TestGetAllSpender(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "email"}).
 			AddRow(1, "HongJot", "hong@jot.ok").
 			AddRow(2, "JotHong", "jot@jot.ok")
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender ORDER BY id`).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetAll(c)
 
 		assert.NoError(t, err)
@@ -135,13 +143,13 @@ func TestGetAllSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender ORDER BY id`).WillReturnError(assert.AnError)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetAll(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 }
 
@@ -163,9 +171,9 @@ func TestGetSpender(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "email"}).
 			AddRow(1, "HongJot", "hong@jot.ok").
 			AddRow(2, "JotHong", "jot@jot.ok")
-		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id=$1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id=$1`).WithArgs(1).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.Get(c)
 
 		assert.NoError(t, err)
@@ -173,47 +181,45 @@ func TestGetSpender(t *testing.T) {
 		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok"}`, rec.Body.String())
 	})
 
-	t.Run("test get spender with non integer ID", func(t *testing.T) {
+	t.Run("get spender not found", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
-		req := httptest.NewRequest(http.MethodGet, "/non-int", nil)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
 		c.SetParamNames("id")
-		c.SetParamValues("non-int")
+		c.SetParamValues("404")
 
-		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-		defer db.Close()
-
-		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id=$1`).WithArgs("non-int")
-
-		h := New(config.FeatureFlag{}, db)
+		h := New(NewMemoryStore(), transaction.NewMemoryStore())
 		err := h.Get(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, problem.From(err).Status)
 	})
 
-	t.Run("update spender feature is disabled", func(t *testing.T) {
+	t.Run("test get spender with non integer ID", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
-		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"name": "HongJot", "email": "a1@a.com"}`))
-		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req := httptest.NewRequest(http.MethodGet, "/non-int", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		c.SetPath("/spenders/:id")
+
 		c.SetParamNames("id")
-		c.SetParamValues("1")
+		c.SetParamValues("non-int")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
 
-		cfg := config.FeatureFlag{EnableUpdateSpender: false}
-		h := New(cfg, nil)
-		err := h.Update(c)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id=$1`).WithArgs("non-int")
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusForbidden, rec.Code)
+		h := newHandler(db)
+		err := h.Get(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 }
@@ -237,9 +243,9 @@ func TestTransactionBySpenderId(t *testing.T) {
 		//SELECT id, sender_id, date, amount, category, transaction_type, note, image_url FROM
 		rows := sqlmock.NewRows([]string{"id", "spender_id", "date", "amount", "category", "transaction_type", "note", "image_url"}).
 			AddRow(1, 1, expectedDate, 1000.00, "Food", "expense", "Lunch", "https://example.com/image1.jpg")
-		mock.ExpectQuery(`SELECT id, spender_id, date, amount, category, transaction_type, note, image_url FROM transaction WHERE spender_id=$1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, spender_id, date, amount, category, transaction_type, note, image_url FROM transaction WHERE spender_id=$1 ORDER BY id`).WithArgs(1).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetTransactions(c)
 
 		assert.NoError(t, err)
@@ -267,11 +273,11 @@ func TestTransactionBySpenderId(t *testing.T) {
 		mock.ExpectQuery(`SELECT id, spender_id, date, amount, category, transaction_type,
 		note, image_url FROM transaction WHERE spender_id=$1`).WithArgs("non-int")
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.Get(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 }
 
@@ -295,9 +301,9 @@ func TestGetSummary(t *testing.T) {
 			AddRow(2000, "expense").
 			AddRow(1000, "income").
 			AddRow(3000, "income")
-		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs(1).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetSummary(c)
 
 		assert.NoError(t, err)
@@ -328,9 +334,9 @@ func TestGetSummary(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"amount", "transaction_type"}).
 			AddRow(1500, "expense").
 			AddRow(2000, "expense")
-		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs(1).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetSummary(c)
 
 		assert.NoError(t, err)
@@ -361,9 +367,9 @@ func TestGetSummary(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"amount", "transaction_type"}).
 			AddRow(1000, "income").
 			AddRow(3000, "income")
-		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs(1).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetSummary(c)
 
 		assert.NoError(t, err)
@@ -395,11 +401,11 @@ func TestGetSummary(t *testing.T) {
 		note, image_url FROM transaction WHERE spender_id=$1`).WithArgs("non-int")
 		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs("non-int")
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetSummary(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("get summary failed on database", func(t *testing.T) {
@@ -413,12 +419,12 @@ func TestGetSummary(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs("1").WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`).WithArgs(1).WillReturnError(assert.AnError)
 
-		h := New(config.FeatureFlag{}, db)
+		h := newHandler(db)
 		err := h.GetAll(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 }
@@ -0,0 +1,19 @@
+package spender
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("spender not found")
+
+// SpenderStore is where spenders are kept. Get and Update return ErrNotFound
+// for an unknown id, and writes that break a schema rule return an error
+// database.Constraint recognizes.
+type SpenderStore interface {
+	List(ctx context.Context) ([]Spender, error)
+	Get(ctx context.Context, id int64) (Spender, error)
+	// Create stores sp and returns the id it was given.
+	Create(ctx context.Context, sp Spender) (int64, error)
+	Update(ctx context.Context, sp Spender) error
+}
@@ -0,0 +1,24 @@
+//go:build integration
+
+package spender
+
+import (
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStoreIT(t *testing.T) {
+	db, err := getTestDatabaseFromConfig()
+	require.NoError(t, err)
+	defer db.Close()
+
+	newStore := func(t *testing.T) SpenderStore {
+		require.NoError(t, migration.ApplyMigrations(db))
+		t.Cleanup(func() { require.NoError(t, migration.RollbackMigrations(db)) })
+		return NewPostgresStore(db)
+	}
+	testSpenderStore(t, newStore)
+	testSpenderRules(t, newStore)
+}
@@ -0,0 +1,144 @@
+package spender
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
+	_ "github.com/proullon/ramsql/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func createSpender(t *testing.T, s SpenderStore, name, email string) Spender {
+	sp := Spender{Name: name, Email: email}
+	id, err := s.Create(context.Background(), sp)
+	require.NoError(t, err)
+	sp.ID = id
+	return sp
+}
+
+// testSpenderStore is the contract every SpenderStore keeps. newStore must
+// return an empty store.
+func testSpenderStore(t *testing.T, newStore func(t *testing.T) SpenderStore) {
+	ctx := context.Background()
+
+	t.Run("create gives a new id and get returns the spender", func(t *testing.T) {
+		s := newStore(t)
+		first := createSpender(t, s, "HongJot", "hong@jot.ok")
+		second := createSpender(t, s, "JotHong", "jot@jot.ok")
+		assert.NotEqual(t, first.ID, second.ID)
+
+		got, err := s.Get(ctx, first.ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, first, got)
+	})
+
+	t.Run("get an unknown id is ErrNotFound", func(t *testing.T) {
+		_, err := newStore(t).Get(ctx, 404)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("update replaces the spender", func(t *testing.T) {
+		s := newStore(t)
+		sp := createSpender(t, s, "HongJot", "hong@jot.ok")
+		sp.Name = "Hong Jot"
+
+		err := s.Update(ctx, sp)
+
+		assert.NoError(t, err)
+		got, err := s.Get(ctx, sp.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, sp, got)
+	})
+
+	t.Run("update an unknown id is ErrNotFound", func(t *testing.T) {
+		err := newStore(t).Update(ctx, Spender{ID: 404, Name: "HongJot", Email: "hong@jot.ok"})
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("list returns every spender in id order", func(t *testing.T) {
+		s := newStore(t)
+		empty, err := s.List(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, empty)
+		want := []Spender{createSpender(t, s, "HongJot", "hong@jot.ok"), createSpender(t, s, "JotHong", "jot@jot.ok")}
+
+		got, err := s.List(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+}
+
+// testSpenderRules checks a SpenderStore enforces the rules of the schema as
+// Postgres does. The ramsql backend does not, see migration/ramsql.
+func testSpenderRules(t *testing.T, newStore func(t *testing.T) SpenderStore) {
+	ctx := context.Background()
+	conflict := func(t *testing.T, err error) {
+		ce, ok := database.Constraint(err)
+		require.True(t, ok, "got %v", err)
+		assert.Equal(t, http.StatusConflict, ce.Status)
+		assert.Equal(t, "spender_email_key", ce.Constraint)
+	}
+
+	t.Run("an email is used by one spender whatever its case", func(t *testing.T) {
+		s := newStore(t)
+		createSpender(t, s, "HongJot", "hong@jot.ok")
+		other := createSpender(t, s, "JotHong", "jot@jot.ok")
+
+		_, err := s.Create(ctx, Spender{Name: "Copy", Email: "HONG@jot.ok"})
+		conflict(t, err)
+
+		other.Email = "Hong@Jot.ok"
+		conflict(t, s.Update(ctx, other))
+
+		other.Email = "JOT@jot.ok"
+		assert.NoError(t, s.Update(ctx, other))
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	newStore := func(t *testing.T) SpenderStore {
+		return NewMemoryStore()
+	}
+	testSpenderStore(t, newStore)
+	testSpenderRules(t, newStore)
+}
+
+func TestPostgresStoreOnRamSQL(t *testing.T) {
+	if raceEnabled {
+		t.Skip("ramsql fails checkptr under the race detector")
+	}
+	testSpenderStore(t, func(t *testing.T) SpenderStore {
+		db, err := sql.Open("ramsql", t.Name())
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, migration.ApplyFor(context.Background(), db, "ramsql", zap.NewNop()))
+		return NewRamSQLStore(db)
+	})
+}
+
+func TestPostgresStoreUpdateError(t *testing.T) {
+	t.Run("should return the error of a failed update as is", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		mock.ExpectExec(regexp.QuoteMeta(uStmt)).WillReturnError(assert.AnError)
+		// were the row looked up, it would be missing and hide the failure
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(nil))
+
+		err := NewPostgresStore(db).Update(context.Background(), Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok"})
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+}
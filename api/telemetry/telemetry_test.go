@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	t.Run("installs nothing when disabled", func(t *testing.T) {
+		before := otel.GetTracerProvider()
+
+		shutdown, err := Setup(context.Background(), config.Tracing{Enabled: false})
+
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+		assert.Equal(t, before, otel.GetTracerProvider())
+	})
+
+	t.Run("exports spans to the otlp endpoint", func(t *testing.T) {
+		before, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+		t.Cleanup(func() {
+			otel.SetTracerProvider(before)
+			otel.SetTextMapPropagator(propagator)
+		})
+
+		var mu sync.Mutex
+		var paths []string
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, r.URL.Path)
+		}))
+		defer collector.Close()
+
+		shutdown, err := Setup(context.Background(), config.Tracing{
+			Enabled:     true,
+			Endpoint:    strings.TrimPrefix(collector.URL, "http://"),
+			Insecure:    true,
+			ServiceName: "hongjot-test",
+		})
+		assert.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "work")
+		span.End()
+
+		assert.NoError(t, shutdown(context.Background()))
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"/v1/traces"}, paths)
+		assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+	})
+}
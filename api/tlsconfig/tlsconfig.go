@@ -0,0 +1,172 @@
+// Package tlsconfig builds the server's *tls.Config from config.TLS.
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"go.uber.org/zap"
+)
+
+// New returns nil when TLS is off. With http2 the server offers h2 through
+// ALPN, otherwise only http/1.1.
+func New(cfg config.TLS, http2 bool, logger *zap.Logger) (*tls.Config, error) {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+	}
+	if http2 {
+		base.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	switch cfg.Mode {
+	case "", "off":
+		return nil, nil
+	case "self-signed":
+		cert, err := SelfSigned("localhost", "127.0.0.1", "::1")
+		if err != nil {
+			return nil, err
+		}
+		base.Certificates = []tls.Certificate{cert}
+		if cfg.ClientCAFile == "" {
+			return base, nil
+		}
+	case "file":
+	default:
+		return nil, fmt.Errorf("unknown tls mode %q", cfg.Mode)
+	}
+
+	r := &reloader{cfg: cfg, base: base, logger: logger}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	// http.Server only sets up HTTP/2 when the outer config offers h2.
+	return &tls.Config{NextProtos: base.NextProtos, GetConfigForClient: r.config}, nil
+}
+
+// reloader re-reads the certificate, key and client CAs whenever one of the
+// files changes, so rotated certificates are picked up without a restart.
+// A change that fails to load keeps the last good config.
+type reloader struct {
+	cfg    config.TLS
+	base   *tls.Config
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	modTime time.Time
+	current *tls.Config
+}
+
+func (r *reloader) config(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cfg, err := r.load()
+	if err != nil {
+		r.logger.Warn("failed to reload tls certificates, keeping the previous ones", zap.Error(err))
+	}
+	return cfg, nil
+}
+
+func (r *reloader) files() []string {
+	var files []string
+	if r.cfg.Mode == "file" {
+		files = append(files, r.cfg.CertFile, r.cfg.KeyFile)
+	}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *reloader) load() (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest time.Time
+	for _, f := range r.files() {
+		st, err := os.Stat(f)
+		if err != nil {
+			return r.current, err
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	if r.current != nil && !latest.After(r.modTime) {
+		return r.current, nil
+	}
+
+	cfg := r.base.Clone()
+	if r.cfg.Mode == "file" {
+		cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return r.current, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return r.current, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return r.current, errors.New("no certificates found in " + r.cfg.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if r.current != nil {
+		r.logger.Info("reloaded tls certificates")
+	}
+	r.current, r.modTime = cfg, latest
+	return cfg, nil
+}
+
+// SelfSigned creates a certificate for hosts valid for a year. It is meant
+// for development only; clients have to skip verification or trust it.
+func SelfSigned(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"HongJot development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,191 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newCert creates a self-signed CA certificate usable as a server or client
+// certificate for 127.0.0.1.
+func newCert(t *testing.T, serial int64) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeCert writes cert and its key as PEM, stamping both files with mod.
+func writeCert(t *testing.T, cert tls.Certificate, certFile, keyFile string, mod time.Time) {
+	t.Helper()
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+}
+
+// serve runs an http.Server on a TLS listener the way echo's StartServer does.
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		TLSConfig: cfg,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+	go srv.Serve(tls.NewListener(l, cfg))
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + l.Addr().String()
+}
+
+func get(url string, client *tls.Config) (*http.Response, error) {
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: client, ForceAttemptHTTP2: true}}
+	resp, err := c.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("returns nil when tls is off", func(t *testing.T) {
+		cfg, err := New(config.TLS{Mode: "off"}, true, zap.NewNop())
+
+		assert.NoError(t, err)
+		assert.Nil(t, cfg)
+	})
+
+	t.Run("serves a self-signed certificate", func(t *testing.T) {
+		cfg, err := New(config.TLS{Mode: "self-signed"}, false, zap.NewNop())
+		require.NoError(t, err)
+
+		resp, err := get(serve(t, cfg), &tls.Config{InsecureSkipVerify: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, 1, resp.ProtoMajor)
+		assert.Equal(t, []string{"localhost"}, resp.TLS.PeerCertificates[0].DNSNames)
+	})
+
+	t.Run("negotiates http2 when enabled", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+		writeCert(t, newCert(t, 1), certFile, keyFile, time.Now())
+
+		cfg, err := New(config.TLS{Mode: "file", CertFile: certFile, KeyFile: keyFile}, true, zap.NewNop())
+		require.NoError(t, err)
+
+		resp, err := get(serve(t, cfg), &tls.Config{InsecureSkipVerify: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, resp.ProtoMajor)
+	})
+
+	t.Run("fails on a missing certificate", func(t *testing.T) {
+		_, err := New(config.TLS{Mode: "file", CertFile: "missing.crt", KeyFile: "missing.key"}, false, zap.NewNop())
+
+		assert.Error(t, err)
+	})
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	caFile, caKey := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	client := newCert(t, 2)
+	writeCert(t, newCert(t, 1), certFile, keyFile, time.Now())
+	writeCert(t, client, caFile, caKey, time.Now())
+
+	cfg, err := New(config.TLS{Mode: "file", CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, false, zap.NewNop())
+	require.NoError(t, err)
+	url := serve(t, cfg)
+
+	t.Run("accepts a client certificate signed by the CA", func(t *testing.T) {
+		resp, err := get(url, &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{client}})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("rejects a client without a certificate", func(t *testing.T) {
+		_, err := get(url, &tls.Config{InsecureSkipVerify: true})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a client certificate from another CA", func(t *testing.T) {
+		_, err := get(url, &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{newCert(t, 3)}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Minute)
+	writeCert(t, newCert(t, 1), certFile, keyFile, start)
+
+	cfg, err := New(config.TLS{Mode: "file", CertFile: certFile, KeyFile: keyFile}, false, zap.NewNop())
+	require.NoError(t, err)
+	url := serve(t, cfg)
+	client := &tls.Config{InsecureSkipVerify: true}
+
+	serial := func() int64 {
+		resp, err := get(url, client)
+		require.NoError(t, err)
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	assert.Equal(t, int64(1), serial())
+
+	t.Run("picks up a rotated certificate", func(t *testing.T) {
+		writeCert(t, newCert(t, 2), certFile, keyFile, start.Add(time.Second))
+
+		assert.Equal(t, int64(2), serial())
+	})
+
+	t.Run("keeps the last good certificate when the new one is broken", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+		require.NoError(t, os.Chtimes(certFile, start.Add(2*time.Second), start.Add(2*time.Second)))
+
+		assert.Equal(t, int64(2), serial())
+	})
+}
@@ -0,0 +1,103 @@
+package transaction
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+)
+
+// MemoryStore keeps transactions in a map, for tests and running without a
+// database. It enforces the type and amount checks of the schema but knows
+// nothing about spenders, so any spender_id is accepted.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	rows   map[int64]Transaction
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rows: map[int64]Transaction{}}
+}
+
+func check(ts Transaction) error {
+	if ts.TransactionType != "income" && ts.TransactionType != "expense" {
+		return database.Violation(http.StatusUnprocessableEntity, "transaction_type_check")
+	}
+	if ts.Amount < 0 {
+		return database.Violation(http.StatusUnprocessableEntity, "transaction_amount_check")
+	}
+	return nil
+}
+
+func (s *MemoryStore) filter(keep func(Transaction) bool) []Transaction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ts []Transaction
+	for _, t := range s.rows {
+		if keep(t) {
+			ts = append(ts, t)
+		}
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].ID < ts[j].ID })
+	return ts
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Transaction, error) {
+	return s.filter(func(Transaction) bool { return true }), nil
+}
+
+func (s *MemoryStore) ListBySpender(ctx context.Context, spenderID int64) ([]Transaction, error) {
+	return s.filter(func(t Transaction) bool { return int64(t.SpenderID) == spenderID }), nil
+}
+
+func (s *MemoryStore) Summary(ctx context.Context, spenderID int64) (Summary, error) {
+	var sum Summary
+	ts, _ := s.ListBySpender(ctx, spenderID)
+	for _, t := range ts {
+		sum.add(float64(t.Amount), t.TransactionType)
+	}
+	return sum, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id int64) (Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.rows[id]
+	if !ok {
+		return Transaction{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, ts Transaction) (int64, error) {
+	if err := check(ts); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	ts.ID = s.nextID
+	s.rows[ts.ID] = ts
+	return ts.ID, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, ts Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rows[ts.ID]; !ok {
+		return ErrNotFound
+	}
+	if err := check(ts); err != nil {
+		return err
+	}
+	s.rows[ts.ID] = ts
+	return nil
+}
@@ -0,0 +1,124 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// columns is the order every query selects and scan reads, so adding a
+// column to the table never shifts what lands in which field.
+const columns = `id, spender_id, date, amount, category, transaction_type, note, image_url`
+
+const (
+	cStmt = `INSERT INTO transaction ( spender_id , date , amount , category, transaction_type, note, image_url) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	uStmt = `UPDATE transaction SET spender_id = $1, date = $2, amount = $3, category = $4, transaction_type = $5, note = $6, image_url = $7 WHERE id = $8`
+)
+
+// PostgresStore keeps transactions in the transaction table. Its queries
+// stay within the SQL ramsql understands, so the embedded backend uses it too.
+type PostgresStore struct {
+	db     *sql.DB
+	ramsql bool
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// NewRamSQLStore returns a PostgresStore for the embedded ramsql database,
+// working around where its behaviour differs from Postgres.
+func NewRamSQLStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, ramsql: true}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner) (Transaction, error) {
+	var t Transaction
+	err := row.Scan(&t.ID, &t.SpenderID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.Note, &t.ImageUrl)
+	return t, err
+}
+
+func (s *PostgresStore) list(ctx context.Context, query string, args ...any) ([]Transaction, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ts []Transaction
+	for rows.Next() {
+		t, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, rows.Err()
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]Transaction, error) {
+	return s.list(ctx, `SELECT `+columns+` FROM transaction ORDER BY id`)
+}
+
+func (s *PostgresStore) ListBySpender(ctx context.Context, spenderID int64) ([]Transaction, error) {
+	return s.list(ctx, `SELECT `+columns+` FROM transaction WHERE spender_id=$1 ORDER BY id`, spenderID)
+}
+
+func (s *PostgresStore) Summary(ctx context.Context, spenderID int64) (Summary, error) {
+	var sum Summary
+	rows, err := s.db.QueryContext(ctx, `SELECT amount, transaction_type FROM transaction WHERE spender_id=$1`, spenderID)
+	if err != nil {
+		return sum, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var amount float64
+		var transactionType string
+		if err := rows.Scan(&amount, &transactionType); err != nil {
+			return sum, err
+		}
+		sum.add(amount, transactionType)
+	}
+	return sum, rows.Err()
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id int64) (Transaction, error) {
+	t, err := scan(s.db.QueryRowContext(ctx, `SELECT `+columns+` FROM transaction WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrNotFound
+	}
+	return t, err
+}
+
+func (s *PostgresStore) Create(ctx context.Context, ts Transaction) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, cStmt, ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl).Scan(&id)
+	return id, err
+}
+
+func (s *PostgresStore) Update(ctx context.Context, ts Transaction) error {
+	res, err := s.db.ExecContext(ctx, uStmt, ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl, ts.ID)
+	if err != nil {
+		// ramsql fails an UPDATE that matches no row instead of reporting
+		// none affected
+		if s.ramsql {
+			if _, getErr := s.Get(ctx, ts.ID); errors.Is(getErr, ErrNotFound) {
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
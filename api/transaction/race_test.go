@@ -0,0 +1,7 @@
+//go:build race
+
+package transaction
+
+// raceEnabled is set when testing with -race. ramsql's hash index does
+// pointer arithmetic that the race detector's checkptr aborts the run on.
+const raceEnabled = true
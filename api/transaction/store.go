@@ -0,0 +1,38 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("transaction not found")
+
+// Summary totals a spender's transactions. Any type other than income counts
+// as an expense.
+type Summary struct {
+	TotalIncome    float64
+	TotalExpenses  float64
+	CurrentBalance float64
+}
+
+// TransactionStore is where transactions are kept. Get and Update return
+// ErrNotFound for an unknown id, and writes that break a schema rule return
+// an error database.Constraint recognizes.
+type TransactionStore interface {
+	List(ctx context.Context) ([]Transaction, error)
+	ListBySpender(ctx context.Context, spenderID int64) ([]Transaction, error)
+	Summary(ctx context.Context, spenderID int64) (Summary, error)
+	Get(ctx context.Context, id int64) (Transaction, error)
+	// Create stores ts and returns the id it was given.
+	Create(ctx context.Context, ts Transaction) (int64, error)
+	Update(ctx context.Context, ts Transaction) error
+}
+
+func (s *Summary) add(amount float64, transactionType string) {
+	if transactionType == "income" {
+		s.TotalIncome += amount
+	} else {
+		s.TotalExpenses += amount
+	}
+	s.CurrentBalance = s.TotalIncome - s.TotalExpenses
+}
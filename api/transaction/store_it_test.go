@@ -0,0 +1,29 @@
+//go:build integration
+
+package transaction
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStoreIT(t *testing.T) {
+	db, err := sql.Open("postgres", config.Parse("DOCKER").PostgresURI())
+	require.NoError(t, err)
+	defer db.Close()
+
+	newStore := func(t *testing.T) TransactionStore {
+		require.NoError(t, migration.ApplyMigrations(db))
+		t.Cleanup(func() { require.NoError(t, migration.RollbackMigrations(db)) })
+		_, err := db.Exec(`INSERT INTO spender (id, name, email) VALUES (1, 'HongJot', 'hong@jot.ok'), (2, 'JotHong', 'jot@jot.ok')`)
+		require.NoError(t, err)
+		return NewPostgresStore(db)
+	}
+	testTransactionStore(t, newStore)
+	testTransactionRules(t, newStore)
+}
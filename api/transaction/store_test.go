@@ -0,0 +1,194 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
+	_ "github.com/proullon/ramsql/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testTransactionStore is the contract every TransactionStore keeps.
+// newStore must return an empty store in which spenders 1 and 2 exist.
+func testTransactionStore(t *testing.T, newStore func(t *testing.T) TransactionStore) {
+	ctx := context.Background()
+	date := time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC)
+	lunch := Transaction{SpenderID: 1, Date: date, Amount: 150.5, Category: "Food", TransactionType: "expense", Note: "Lunch", ImageUrl: "https://example.com/lunch.jpg"}
+	salary := Transaction{SpenderID: 1, Date: date, Amount: 3000, Category: "Salary", TransactionType: "income", Note: "April", ImageUrl: ""}
+	rent := Transaction{SpenderID: 2, Date: date, Amount: 1000, Category: "Home", TransactionType: "expense", Note: "Rent", ImageUrl: ""}
+
+	create := func(t *testing.T, s TransactionStore, ts Transaction) Transaction {
+		id, err := s.Create(ctx, ts)
+		require.NoError(t, err)
+		ts.ID = id
+		return ts
+	}
+	same := func(t *testing.T, want, got Transaction) {
+		assert.True(t, want.Date.Equal(got.Date), "date %v, want %v", got.Date, want.Date)
+		got.Date = want.Date
+		assert.Equal(t, want, got)
+	}
+
+	t.Run("create gives a new id and get returns the transaction", func(t *testing.T) {
+		s := newStore(t)
+		first := create(t, s, lunch)
+		second := create(t, s, rent)
+		assert.NotEqual(t, first.ID, second.ID)
+
+		got, err := s.Get(ctx, first.ID)
+
+		assert.NoError(t, err)
+		same(t, first, got)
+	})
+
+	t.Run("get an unknown id is ErrNotFound", func(t *testing.T) {
+		_, err := newStore(t).Get(ctx, 404)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("update replaces the transaction", func(t *testing.T) {
+		s := newStore(t)
+		ts := create(t, s, lunch)
+		ts.Amount = 99
+		ts.Note = "Dinner"
+
+		err := s.Update(ctx, ts)
+
+		assert.NoError(t, err)
+		got, err := s.Get(ctx, ts.ID)
+		assert.NoError(t, err)
+		same(t, ts, got)
+	})
+
+	t.Run("update an unknown id is ErrNotFound", func(t *testing.T) {
+		ts := lunch
+		ts.ID = 404
+
+		err := newStore(t).Update(ctx, ts)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("list returns every transaction in id order", func(t *testing.T) {
+		s := newStore(t)
+		empty, err := s.List(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, empty)
+		want := []Transaction{create(t, s, lunch), create(t, s, rent), create(t, s, salary)}
+
+		got, err := s.List(ctx)
+
+		assert.NoError(t, err)
+		require.Len(t, got, len(want))
+		for i := range want {
+			same(t, want[i], got[i])
+		}
+	})
+
+	t.Run("list by spender returns only theirs", func(t *testing.T) {
+		s := newStore(t)
+		first := create(t, s, lunch)
+		create(t, s, rent)
+		second := create(t, s, salary)
+
+		got, err := s.ListBySpender(ctx, 1)
+
+		assert.NoError(t, err)
+		require.Len(t, got, 2)
+		same(t, first, got[0])
+		same(t, second, got[1])
+		none, err := s.ListBySpender(ctx, 404)
+		assert.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("summary totals a spender's income and expenses", func(t *testing.T) {
+		s := newStore(t)
+		create(t, s, lunch)
+		create(t, s, salary)
+		create(t, s, rent)
+
+		got, err := s.Summary(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Summary{TotalIncome: 3000, TotalExpenses: 150.5, CurrentBalance: 2849.5}, got)
+	})
+}
+
+// testTransactionRules checks a TransactionStore enforces the rules of the
+// schema as Postgres does. The ramsql backend does not, see migration/ramsql.
+func testTransactionRules(t *testing.T, newStore func(t *testing.T) TransactionStore) {
+	ctx := context.Background()
+	lunch := Transaction{SpenderID: 1, Date: time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC), Amount: 150.5, Category: "Food", TransactionType: "expense", Note: "Lunch"}
+
+	t.Run("writes that break a rule are constraint errors", func(t *testing.T) {
+		s := newStore(t)
+		badType := lunch
+		badType.TransactionType = "gift"
+		negative := lunch
+		negative.Amount = -1
+
+		for name, ts := range map[string]Transaction{"transaction_type_check": badType, "transaction_amount_check": negative} {
+			_, err := s.Create(ctx, ts)
+
+			ce, ok := database.Constraint(err)
+			require.True(t, ok, "%s: %v", name, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, ce.Status)
+			assert.Equal(t, name, ce.Constraint)
+		}
+
+		id, err := s.Create(ctx, lunch)
+		require.NoError(t, err)
+		ts := lunch
+		ts.ID = id
+		ts.Amount = -1
+		_, ok := database.Constraint(s.Update(ctx, ts))
+		assert.True(t, ok)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	newStore := func(t *testing.T) TransactionStore {
+		return NewMemoryStore()
+	}
+	testTransactionStore(t, newStore)
+	testTransactionRules(t, newStore)
+}
+
+func TestPostgresStoreOnRamSQL(t *testing.T) {
+	if raceEnabled {
+		t.Skip("ramsql fails checkptr under the race detector")
+	}
+	testTransactionStore(t, func(t *testing.T) TransactionStore {
+		db, err := sql.Open("ramsql", t.Name())
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, migration.ApplyFor(context.Background(), db, "ramsql", zap.NewNop()))
+		return NewRamSQLStore(db)
+	})
+}
+
+func TestPostgresStoreUpdateError(t *testing.T) {
+	t.Run("should return the error of a failed update as is", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		mock.ExpectExec(regexp.QuoteMeta(uStmt)).WillReturnError(assert.AnError)
+		// were the row looked up, it would be missing and hide the failure
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(nil))
+
+		err := NewPostgresStore(db).Update(context.Background(), Transaction{ID: 1, SpenderID: 1})
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -1,14 +1,16 @@
 package transaction
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"time"
 
-	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/constanst"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -26,119 +28,112 @@ type Transaction struct {
 }
 
 type handler struct {
-	flag config.FeatureFlag
-	db   *sql.DB
+	store TransactionStore
 }
 
-func New(cfg config.FeatureFlag, db *sql.DB) *handler {
-	return &handler{cfg, db}
+func New(store TransactionStore) *handler {
+	return &handler{store}
 }
 
-const (
-	cStmt = `INSERT INTO transaction ( spender_id , date , amount , category, transaction_type, note, image_url) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
-)
-
 func (h handler) Get(c echo.Context) error {
 	logger := mlog.L(c)
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		logger.Error("bad request id", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
-	ctx := c.Request().Context()
-	var ts Transaction
-	err = h.db.QueryRowContext(ctx, "SELECT * FROM transaction WHERE id = $1", id).Scan(&ts.ID, &ts.SpenderID, &ts.Date, &ts.Amount, &ts.Category, &ts.TransactionType, &ts.Note, &ts.ImageUrl)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+	ts, err := h.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return problem.NotFound("transaction_not_found", "transaction not found")
+	}
 	if err != nil {
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	logger.Info("get successfully", zap.Int64("id", id))
 	return c.JSON(http.StatusOK, ts)
 }
 
-
-
 func (h handler) Create(c echo.Context) error {
-	if !h.flag.EnableCreateTransaction {
-		return c.JSON(http.StatusForbidden, "create new transaction feature is disabled")
-	}
-
 	logger := mlog.L(c)
 
 	var ts Transaction
 	err := c.Bind(&ts)
 	if err != nil {
 		logger.Error(constanst.BadRequestBody, zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
 	}
 
-	ctx := c.Request().Context()
-	var lastInsertId int64
-	err = h.db.QueryRowContext(ctx, cStmt, ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl).Scan(&lastInsertId)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+	lastInsertId, err := h.store.Create(ctx, ts)
 	if err != nil {
+		if ce, ok := database.Constraint(err); ok {
+			logger.Warn(ce.Message, zap.String("constraint", ce.Constraint), zap.Error(err))
+			return ce.Problem()
+		}
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	logger.Info("create successfully", zap.Int64("id", lastInsertId))
+	metrics.TransactionCreated(ts.TransactionType)
 	ts.ID = lastInsertId
 	return c.JSON(http.StatusCreated, ts)
 }
 
 func (h handler) Update(c echo.Context) error {
-	// if !h.flag.EnableUpdateTransaction {
-	// 	return c.JSON(http.StatusForbidden, "update transaction feature is disabled")
-	// }
-
 	logger := mlog.L(c)
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	updateID := id
 	if err != nil {
 		logger.Error("bad request id", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_id", "id must be an integer")
 	}
 
 	var ts Transaction
 	err = c.Bind(&ts)
 	if err != nil {
 		logger.Error(constanst.BadRequestBody, zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Validation("invalid_body", "request body is invalid").Wrap(err)
 	}
+	ts.ID = id
 
-	ctx := c.Request().Context()
-	_, err = h.db.ExecContext(ctx, "UPDATE transaction SET spender_id = $1, date = $2, amount = $3, category = $4, transaction_type = $5, note = $6, image_url = $7 WHERE id = $8", ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl, id)
+	ctx, cancel := database.Context(c)
+	defer cancel()
+	err = h.store.Update(ctx, ts)
+	if errors.Is(err, ErrNotFound) {
+		return problem.NotFound("transaction_not_found", "transaction not found")
+	}
 	if err != nil {
+		if ce, ok := database.Constraint(err); ok {
+			logger.Warn(ce.Message, zap.String("constraint", ce.Constraint), zap.Error(err))
+			return ce.Problem()
+		}
 		logger.Error("exec error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
-	logger.Info("update successfully", zap.Int64("id", updateID))
-	ts.ID = updateID
+	logger.Info("update successfully", zap.Int64("id", id))
 	return c.JSON(http.StatusOK, ts)
 }
 
 func (h handler) GetAll(c echo.Context) error {
 	logger := mlog.L(c)
-	ctx := c.Request().Context()
+	ctx, cancel := database.Context(c)
+	defer cancel()
 
-	rows, err := h.db.QueryContext(ctx, `SELECT * FROM transaction`)
+	ts, err := h.store.List(ctx)
 	if err != nil {
 		logger.Error(constanst.QueryError, zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
-	}
-	defer rows.Close()
-
-	var ts []Transaction
-	for rows.Next() {
-		var t Transaction
-		err := rows.Scan(&t.ID, &t.SpenderID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.Note, &t.ImageUrl)
-		if err != nil {
-			logger.Error(constanst.ScanError, zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
-		}
-		ts = append(ts, t)
+		metrics.DBError()
+		return problem.Internal(err)
 	}
 
 	return c.JSON(http.StatusOK, ts)
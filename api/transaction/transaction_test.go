@@ -8,8 +8,9 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/problem"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,9 +52,8 @@ func TestCreateTransaction(t *testing.T) {
 
 		row := sqlmock.NewRows([]string{"id"}).AddRow(1)
 		mock.ExpectQuery(cStmt).WithArgs(ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl).WillReturnRows(row)
-		cfg := config.FeatureFlag{EnableCreateTransaction: true}
 
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Create(c)
 
 		assert.NoError(t, err)
@@ -70,13 +70,13 @@ func TestCreateTransaction(t *testing.T) {
 		}`, rec.Body.String())
 	})
 
-	t.Run("create transaction failed when feature toggle is disable", func(t *testing.T) {
+	t.Run("create transaction rejected when spender does not exist", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
 		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{
 			"date": "2024-04-30T09:00:00.000Z",
-			"spender_id":1,
+			"spender_id": 99,
 			"amount": 1500,
 			"category": "Food",
 			"transaction_type": "expense",
@@ -88,13 +88,19 @@ func TestCreateTransaction(t *testing.T) {
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		cfg := config.FeatureFlag{EnableCreateTransaction: false}
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
 
-		h := New(cfg, nil)
+		parsedDate, _ := time.Parse(time.RFC3339, "2024-04-30T09:00:00.000Z")
+		mock.ExpectQuery(cStmt).WithArgs(99, parsedDate, float32(1500), "Food", "expense", "Lunch", "https://example.com/image1.jpg").
+			WillReturnError(&pq.Error{Code: "23503", Constraint: "transaction_spender_id_fkey"})
+
+		h := New(NewPostgresStore(db))
 		err := h.Create(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.From(err).Status)
+		assert.ErrorContains(t, err, "spender does not exist")
 	})
 
 	t.Run("create transaction failed when bad request body", func(t *testing.T) {
@@ -105,12 +111,12 @@ func TestCreateTransaction(t *testing.T) {
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		cfg := config.FeatureFlag{EnableCreateTransaction: true}
 
-		h := New(cfg, nil)
+		h := New(nil)
 		err := h.Create(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("get transaction successfully", func(t *testing.T) {
@@ -142,11 +148,9 @@ func TestCreateTransaction(t *testing.T) {
 		}
 
 		row := sqlmock.NewRows([]string{"id", "spender_id", "date", "amount", "category", "transaction_type", "note", "image_url"}).AddRow(ts.ID, ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl)
-		mock.ExpectQuery("SELECT * FROM transaction WHERE id = $1").WithArgs(ts.ID).WillReturnRows(row)
-
-		cfg := config.FeatureFlag{EnableCreateTransaction: true}
+		mock.ExpectQuery(`SELECT ` + columns + ` FROM transaction WHERE id = $1`).WithArgs(ts.ID).WillReturnRows(row)
 
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Get(c)
 
 		assert.NoError(t, err)
@@ -163,6 +167,24 @@ func TestCreateTransaction(t *testing.T) {
 		}`, rec.Body.String())
 	})
 
+	t.Run("get transaction not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/transactions/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("404")
+
+		h := New(NewMemoryStore())
+		err := h.Get(c)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, problem.From(err).Status)
+	})
+
 	t.Run("get transaction failed when bad request id", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
@@ -174,12 +196,11 @@ func TestCreateTransaction(t *testing.T) {
 		c.SetParamNames("id")
 		c.SetParamValues("bad id")
 
-		cfg := config.FeatureFlag{EnableCreateTransaction: true}
-
-		h := New(cfg, nil)
+		h := New(nil)
 		err := h.Get(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("get transaction failed when query error", func(t *testing.T) {
@@ -200,14 +221,13 @@ func TestCreateTransaction(t *testing.T) {
 			ID: 1,
 		}
 
-		mock.ExpectQuery("SELECT * FROM transaction WHERE id = $1").WithArgs(ts.ID).WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT ` + columns + ` FROM transaction WHERE id = $1`).WithArgs(ts.ID).WillReturnError(assert.AnError)
 
-		cfg := config.FeatureFlag{EnableCreateTransaction: true}
-
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Get(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 
 	t.Run("get all transaction succesfully", func(t *testing.T) {
@@ -227,9 +247,9 @@ func TestCreateTransaction(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "spender_id", "date", "amount", "category", "transaction_type", "note", "image_url"}).
 			AddRow(1, 1, parsedDate, 1500, "Food", "expense", "Lunch", "https://example.com/image1.jpg").
 			AddRow(2, 1, parsedDate, 1500, "Food", "expense", "Lunch", "https://example.com/image1.jpg")
-		mock.ExpectQuery(`SELECT * FROM transaction`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT ` + columns + ` FROM transaction ORDER BY id`).WillReturnRows(rows)
 
-		h := New(config.FeatureFlag{}, db)
+		h := New(NewPostgresStore(db))
 		err := h.GetAll(c)
 
 		assert.NoError(t, err)
@@ -270,20 +290,20 @@ func TestCreateTransaction(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT * FROM transaction`).WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT ` + columns + ` FROM transaction ORDER BY id`).WillReturnError(assert.AnError)
 
-		h := New(config.FeatureFlag{}, db)
+		h := New(NewPostgresStore(db))
 		err := h.GetAll(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 
 }
 
 func TestUpdateTransaction(t *testing.T) {
 
-	t.Run("update transaction failed when feature toggle is disable", func(t *testing.T) {
+	t.Run("update transaction failed when id is missing", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
@@ -301,13 +321,11 @@ func TestUpdateTransaction(t *testing.T) {
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		cfg := config.FeatureFlag{EnableUpdateTransaction: false}
-
-		h := New(cfg, nil)
+		h := New(nil)
 		err := h.Update(c)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("update transaction failed when bad request body", func(t *testing.T) {
@@ -318,12 +336,12 @@ func TestUpdateTransaction(t *testing.T) {
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
 
-		h := New(cfg, nil)
+		h := New(nil)
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("update transaction failed when query error", func(t *testing.T) {
@@ -351,14 +369,13 @@ func TestUpdateTransaction(t *testing.T) {
 			ID: 1,
 		}
 
-		mock.ExpectQuery("SELECT * FROM transaction WHERE id = $1").WithArgs(ts.ID).WillReturnError(assert.AnError)
-
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
+		mock.ExpectQuery(`SELECT ` + columns + ` FROM transaction WHERE id = $1`).WithArgs(ts.ID).WillReturnError(assert.AnError)
 
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("update transaction failed when wrong id", func(t *testing.T) {
@@ -382,12 +399,11 @@ func TestUpdateTransaction(t *testing.T) {
 		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
-
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("update transaction successfully", func(t *testing.T) {
@@ -430,9 +446,7 @@ func TestUpdateTransaction(t *testing.T) {
 
 		mock.ExpectExec("UPDATE transaction SET spender_id = $1, date = $2, amount = $3, category = $4, transaction_type = $5, note = $6, image_url = $7 WHERE id = $8").WithArgs(ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl, ts.ID).WillReturnResult(sqlmock.NewResult(1, 1))
 
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
-
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Update(c)
 
 		assert.NoError(t, err)
@@ -479,12 +493,11 @@ func TestUpdateTransaction(t *testing.T) {
 
 		mock.ExpectExec("UPDATE transaction SET spender_id = $1, date = $2, amount = $3, category = $4, transaction_type = $5, note = $6, image_url = $7 WHERE id = $8").WithArgs(ts.SpenderID, ts.Date, ts.Amount, ts.Category, ts.TransactionType, ts.Note, ts.ImageUrl, ts.ID).WillReturnError(assert.AnError)
 
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
-
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, problem.From(err).Status)
 	})
 
 	t.Run("update transaction failed when wrong id", func(t *testing.T) {
@@ -511,12 +524,11 @@ func TestUpdateTransaction(t *testing.T) {
 		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
-
-		h := New(cfg, db)
+		h := New(NewPostgresStore(db))
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 	t.Run("update transaction failed when bad request body", func(t *testing.T) {
@@ -530,12 +542,12 @@ func TestUpdateTransaction(t *testing.T) {
 		c.SetPath("/transactions/:id")
 		c.SetParamNames("id")
 		c.SetParamValues("1")
-		cfg := config.FeatureFlag{EnableUpdateTransaction: true}
 
-		h := New(cfg, nil)
+		h := New(nil)
 		err := h.Update(c)
 
-		assert.NoError(t, err)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, problem.From(err).Status)
 	})
 
 }
@@ -2,57 +2,171 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/KKGo-Software-engineering/workshop-summer/api"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/database"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/metrics"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/telemetry"
 	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/gommon/log"
-	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 func main() {
 	env := config.Env("ENV")
-	cfg := config.Parse(env)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(env, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		seed(env, os.Args[2:])
+		return
+	}
 
-	db, err := sql.Open("postgres", cfg.Database.PostgresURI)
+	cfg, err := config.Load(env, os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := migration.ApplyMigrations(db); err != nil {
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	logger, err := zap.NewProduction()
+	logger, levels, err := mlog.New(cfg.Log)
 	if err != nil {
 		log.Fatal(err)
 	}
+	logger.Info("effective config", zap.Any("config", cfg.Redacted()))
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		logger.Fatal("failed to open the database", zap.Error(err))
+	}
+	if cfg.Database.AutoMigrate {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.MigrateTimeout)
+		err := migration.ApplyFor(ctx, db, cfg.Database.Driver, logger)
+		cancel()
+		if err != nil {
+			logger.Fatal("failed to migrate the database", zap.Error(err))
+		}
+	}
 
-	e := api.New(db, cfg, logger)
+	e := api.New(db, cfg, logger, levels)
 
 	go func() { // comment here to simulate slow endpoint then Ctrl+C to stop the server
-		if err := e.Start(":" + cfg.Server.Port); err != nil && err != http.ErrServerClosed {
+		if err := e.Serve(cfg.Server, logger); err != nil && err != http.ErrServerClosed {
 			logger.Fatal("shutting down the server:", zap.Error(err))
 		}
 	}()
 
-	logger.Info("Server is running on :%s", zap.String("port", cfg.Server.Port))
+	metricsSrv := metrics.NewServer(cfg.Metrics.Port)
+	if cfg.Metrics.Port != "" {
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("shutting down the metrics server:", zap.Error(err))
+			}
+		}()
+		logger.Info("metrics are served", zap.String("addr", ":"+cfg.Metrics.Port), zap.String("path", "/metrics"))
+	}
+
+	logger.Info("Server is running on :%s", zap.String("port", cfg.Server.Port), zap.String("tls", cfg.Server.TLS.Mode), zap.Bool("http2", cfg.Server.HTTP2))
 
 	// Wait for interrupt signal to gracefully shutdown the server with a timeout of 10 seconds.
-	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// Kubernetes stops pods with SIGTERM
+	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	<-sig.Done()
+	logger.Info("draining traffic before shutdown", zap.Duration("delay", cfg.Health.DrainDelay))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
 		logger.Fatal("shutting down the server:", zap.Error(err))
 	}
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		logger.Error("shutting down the metrics server:", zap.Error(err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", zap.Error(err))
+	}
 	logger.Info("server shutdown gracefully")
 }
+
+// migrate runs "app migrate <command> [flags]" with the same config flags
+// as the server. "app migrate create <name>" only writes a new file into
+// ./migration, so it is meant for a source checkout and needs no database.
+func migrate(env string, args []string) {
+	if len(args) == 0 || !slices.Contains(migration.Commands, args[0]) {
+		log.Fatalf("usage: app migrate %s [flags]", strings.Join(migration.Commands, "|"))
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("usage: app migrate create <name>")
+		}
+		path, err := migration.Create("migration", args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("created", path)
+		return
+	}
+
+	cfg, err := config.Load(env, args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.Driver == "ramsql" {
+		log.Fatal("the ramsql database lives in the server process, which migrates it on boot")
+	}
+	logger, _, err := mlog.New(cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		logger.Fatal("failed to open the database", zap.Error(err))
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.MigrateTimeout)
+	defer cancel()
+	if err := migration.Run(ctx, db, args[0], logger); err != nil {
+		logger.Fatal("migrate "+args[0]+" failed", zap.Error(err))
+	}
+}
+
+// seed runs "app seed [flags]", loading the data set database.seed names.
+func seed(env string, args []string) {
+	cfg, err := config.Load(env, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.Seed == "" {
+		log.Fatal("database.seed is not set, nothing to seed")
+	}
+	if cfg.Database.Driver == "ramsql" {
+		log.Fatal("the ramsql database lives in the server process, seed needs postgres")
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := migration.Seed(context.Background(), db, cfg.Database.Seed); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "slip" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL,
+  object_key VARCHAR(255) NOT NULL,
+  filename VARCHAR(255) DEFAULT '',
+  content_type VARCHAR(100) DEFAULT '',
+  size BIGINT DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "slip";
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "slip" ADD status VARCHAR(20) NOT NULL DEFAULT 'stored';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "slip" DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
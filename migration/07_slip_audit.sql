@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "slip_audit" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL,
+  slip_id INT,
+  object_key VARCHAR(255) NOT NULL,
+  event VARCHAR(50) NOT NULL,
+  detail TEXT DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "slip_audit";
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "feature_flag" ADD COLUMN IF NOT EXISTS rules JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "feature_flag" DROP COLUMN IF EXISTS rules;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A spender's transactions are financial records, so deleting a spender that
+-- still has some is refused rather than cascaded. NOT VALID checks new rows
+-- right away without failing the deploy on rows written before the rules.
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_spender_id_fkey
+  FOREIGN KEY (spender_id) REFERENCES spender (id) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_type_check
+  CHECK (transaction_type IN ('income', 'expense')) NOT VALID;
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_amount_check
+  CHECK (amount >= 0) NOT VALID;
+
+-- listing a spender's transactions, which ListBySpender orders by id
+CREATE INDEX IF NOT EXISTS transaction_spender_id_id_idx ON "transaction" (spender_id, id);
+-- the summary sums amount per type without touching the table
+CREATE INDEX IF NOT EXISTS transaction_spender_id_type_idx ON "transaction" (spender_id, transaction_type) INCLUDE (amount);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_spender_id_type_idx;
+DROP INDEX IF EXISTS transaction_spender_id_id_idx;
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_amount_check;
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_type_check;
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_spender_id_fkey;
+-- +goose StatementEnd
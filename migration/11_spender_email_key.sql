@@ -0,0 +1,13 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Built CONCURRENTLY so spenders stay writable while it builds, which cannot
+-- run inside a transaction. Unlike the NOT VALID constraints, a unique index
+-- checks the rows already there: if two spenders share an email ignoring
+-- case, this fails and leaves an invalid index behind. Find them with
+--   SELECT lower(email), array_agg(id) FROM spender GROUP BY 1 HAVING count(*) > 1;
+-- merge or rename them, DROP INDEX CONCURRENTLY spender_email_key, and run
+-- migrate up again.
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS spender_email_key ON spender (lower(email));
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS spender_email_key;
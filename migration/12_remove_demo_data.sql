@@ -0,0 +1,44 @@
+-- +goose Up
+-- 01 and 03 used to insert demo rows, which now live in migration/seeds/dev.
+-- Only rows still exactly as inserted are removed, so a database whose demo
+-- spenders picked up real transactions or slips keeps them. A dev database
+-- seeded before this migration loses the demo rows as well; run seed again
+-- after clearing goose_seed_dev.
+-- +goose StatementBegin
+DELETE FROM transaction t
+USING (VALUES
+  ('2021-07-01 00:00:00'::timestamptz, 1, 100, 'Food', 'expense', 'Lunch'),
+  ('2021-07-02 00:00:00'::timestamptz, 1, 200, 'Transport', 'income', 'Bus'),
+  ('2021-07-03 00:00:00'::timestamptz, 1, 300, 'Food', 'income', 'Dinner'),
+  ('2021-07-04 00:00:00'::timestamptz, 1, 400, 'Transport', 'income', 'Taxi'),
+  ('2021-07-05 00:00:00'::timestamptz, 1, 500, 'Food', 'income', 'Breakfast'),
+  ('2021-07-06 00:00:00'::timestamptz, 1, 600, 'Transport', 'income', 'Bus'),
+  ('2021-07-07 00:00:00'::timestamptz, 1, 700, 'Food', 'income', 'Lunch'),
+  ('2021-07-08 00:00:00'::timestamptz, 2, 800, 'Transport', 'income', 'Bus'),
+  ('2021-07-09 00:00:00'::timestamptz, 2, 900, 'Food', 'income', 'Dinner'),
+  ('2021-07-10 00:00:00'::timestamptz, 2, 1000, 'Transport', 'income', 'Taxi'),
+  ('2021-07-11 00:00:00'::timestamptz, 2, 1100, 'Food', 'income', 'Breakfast'),
+  ('2021-07-12 00:00:00'::timestamptz, 2, 1200, 'Transport', 'expense', 'Bus'),
+  ('2021-07-13 00:00:00'::timestamptz, 1, 1300, 'Food', 'expense', 'Lunch'),
+  ('2021-07-14 00:00:00'::timestamptz, 1, 1400, 'Transport', 'expense', 'Bus'),
+  ('2021-07-15 00:00:00'::timestamptz, 1, 1500, 'Food', 'expense', 'Dinner'),
+  ('2021-07-16 00:00:00'::timestamptz, 1, 1600, 'Transport', 'expense', 'Taxi')
+) AS demo (date, spender_id, amount, category, transaction_type, note)
+WHERE t.date = demo.date
+  AND t.spender_id = demo.spender_id
+  AND t.amount = demo.amount
+  AND t.category = demo.category
+  AND t.transaction_type = demo.transaction_type
+  AND t.note = demo.note
+  AND t.image_url = '';
+
+DELETE FROM spender s
+WHERE (s.id, s.name, s.email) IN ((1, 'John Doe', 'john001@gmail.com'), (2, 'Jane Doe', 'jane002@gmail.com'))
+  AND NOT EXISTS (SELECT 1 FROM transaction t WHERE t.spender_id = s.id)
+  AND NOT EXISTS (SELECT 1 FROM slip sl WHERE sl.spender_id = s.id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The demo rows are not put back; load them with the dev seed set instead.
+-- +goose StatementEnd
@@ -0,0 +1,90 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+// Commands lists what Run accepts, for usage messages. create is handled by
+// Create since it needs no database.
+var Commands = []string{"up", "down", "status", "redo", "version", "create"}
+
+// Run runs one migrate subcommand on db. Commands that change the schema
+// take the same advisory lock as Apply; status and version print through
+// goose's logger, which is sent to logger.
+func Run(ctx context.Context, db *sql.DB, command string, logger *zap.Logger) error {
+	if err := setup(); err != nil {
+		return err
+	}
+	goose.SetLogger(gooseLogger{logger.Sugar()})
+
+	switch command {
+	case "up":
+		return Apply(ctx, db, logger)
+	case "down":
+		return locked(ctx, db, logger, func() error { return goose.DownContext(ctx, db, ".") }, nil)
+	case "status":
+		return goose.StatusContext(ctx, db, ".")
+	case "redo":
+		return locked(ctx, db, logger, func() error { return goose.RedoContext(ctx, db, ".") }, nil)
+	case "version":
+		return goose.VersionContext(ctx, db, ".")
+	default:
+		return fmt.Errorf("unknown migrate command %q, want one of %s", command, strings.Join(Commands, ", "))
+	}
+}
+
+var (
+	fileName = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+	name     = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+const template = `-- +goose Up
+-- +goose StatementBegin
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- +goose StatementEnd
+`
+
+// Create writes an empty migration into dir, numbered after the newest one
+// there the way the existing files are, e.g. "10_add_index.sql". It returns
+// the path of the new file.
+func Create(dir, migration string) (string, error) {
+	if !name.MatchString(migration) {
+		return "", fmt.Errorf("migration name %q must be lower case letters, digits and underscores", migration)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var last int64
+	for _, e := range entries {
+		if m := fileName.FindStringSubmatch(e.Name()); m != nil {
+			v, _ := strconv.ParseInt(m[1], 10, 64)
+			last = max(last, v)
+		}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%02d_%s.sql", last+1, migration))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(template); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
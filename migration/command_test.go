@@ -0,0 +1,55 @@
+package migration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCreate(t *testing.T) {
+	t.Run("should number after the newest migration", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"01_init.sql", "09_feature_flag_rules.sql", "migration.go"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+		}
+
+		path, err := Create(dir, "add_index")
+
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "10_add_index.sql"), path)
+		b, _ := os.ReadFile(path)
+		assert.Contains(t, string(b), "-- +goose Up")
+		assert.Contains(t, string(b), "-- +goose Down")
+	})
+
+	t.Run("should reject names that do not fit a file name", func(t *testing.T) {
+		_, err := Create(t.TempDir(), "Add Index")
+
+		assert.ErrorContains(t, err, "must be lower case")
+	})
+}
+
+func TestRun(t *testing.T) {
+	err := Run(context.Background(), nil, "sideways", zap.NewNop())
+
+	assert.ErrorContains(t, err, `unknown migrate command "sideways"`)
+}
+
+func TestSeed(t *testing.T) {
+	t.Run("should ship a dev seed set", func(t *testing.T) {
+		entries, err := embedSeeds.ReadDir("seeds/dev")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, entries)
+	})
+
+	t.Run("should reject unknown seed sets before touching the database", func(t *testing.T) {
+		err := Seed(context.Background(), nil, "prod")
+
+		assert.ErrorContains(t, err, `no seed set "prod"`)
+	})
+}
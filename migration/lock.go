@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+// lockKey is the pg_advisory_lock key every replica migrates under. Its
+// value is arbitrary ("hongjot" in ASCII) but must never change.
+const lockKey int64 = 0x686f6e676a6f74
+
+// pollInterval is how often a replica waiting on another one checks again.
+var pollInterval = time.Second
+
+// Apply brings the schema up to the newest embedded migration while holding
+// a Postgres advisory lock, so only one replica migrates at a time. The
+// others wait until the schema reaches that version, or take over the lock
+// should the first one die. ctx bounds the whole wait.
+func Apply(ctx context.Context, db *sql.DB, logger *zap.Logger) error {
+	if err := setup(); err != nil {
+		return err
+	}
+	goose.SetLogger(gooseLogger{logger.Sugar()})
+	want, err := latestVersion()
+	if err != nil {
+		return err
+	}
+
+	return locked(ctx, db, logger, func() error {
+		logger.Info("applying migrations", zap.Int64("target", want))
+		if err := goose.UpContext(ctx, db, "."); err != nil {
+			return err
+		}
+		logger.Info("migrations applied", zap.Int64("version", want))
+		return nil
+	}, func() bool {
+		current, err := currentVersion(ctx, db)
+		if err == nil && current >= want {
+			logger.Info("schema migrated by another replica", zap.Int64("version", current))
+			return true
+		}
+		logger.Info("waiting for another replica to migrate", zap.Int64("version", current), zap.Int64("target", want), zap.NamedError("check", err))
+		return false
+	})
+}
+
+// locked runs fn once it holds the migration lock, trying again every
+// pollInterval until ctx is done. done, if set, is asked between attempts
+// whether there is anything left to do.
+func locked(ctx context.Context, db *sql.DB, logger *zap.Logger, fn func() error, done func() bool) error {
+	// advisory locks belong to a session, so lock and unlock on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&ok); err != nil {
+			return fmt.Errorf("taking the migration lock: %w", err)
+		}
+		if ok {
+			logger.Info("migration lock acquired")
+			defer unlock(conn, logger)
+			return fn()
+		}
+
+		if done != nil && done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the migration lock: %w", ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func unlock(conn *sql.Conn, logger *zap.Logger) {
+	// ctx may be done by now, but the lock must still be released
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+		logger.Error("failed to release the migration lock, dropping its connection", zap.Error(err))
+		// a pooled session would keep holding the lock
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		return
+	}
+	logger.Info("migration lock released")
+}
+
+// latestVersion is the version of the newest embedded migration.
+func latestVersion() (int64, error) {
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return 0, err
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return 0, err
+	}
+	return last.Version, nil
+}
+
+// currentVersion reads the schema version without creating goose's table,
+// which the migrating replica may be doing at the same moment.
+func currentVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var v sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT max(version_id) FROM "+goose.TableName()).Scan(&v)
+	return v.Int64, err
+}
+
+// gooseLogger sends goose's own messages through zap.
+type gooseLogger struct {
+	*zap.SugaredLogger
+}
+
+func (l gooseLogger) Printf(format string, v ...any) {
+	l.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
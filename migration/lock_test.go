@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func expectTryLock(mock sqlmock.Sqlmock, got bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).
+		WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(got))
+}
+
+func TestLocked(t *testing.T) {
+	t.Run("should run fn holding the lock and release it", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		expectTryLock(mock, true)
+		mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		ran := false
+		err := locked(context.Background(), db, zap.NewNop(), func() error { ran = true; return nil }, nil)
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should stop waiting once done says so", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		expectTryLock(mock, false)
+
+		err := locked(context.Background(), db, zap.NewNop(), func() error { t.Fatal("fn must not run"); return nil }, func() bool { return true })
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should give up when ctx is done", func(t *testing.T) {
+		defer func(d time.Duration) { pollInterval = d }(pollInterval)
+		pollInterval = time.Hour
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		expectTryLock(mock, false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := locked(ctx, db, zap.NewNop(), func() error { return nil }, func() bool { return false })
+
+		assert.ErrorContains(t, err, "waiting for the migration lock")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("should not migrate when another replica already has", func(t *testing.T) {
+		assert.NoError(t, setup())
+		latest, err := latestVersion()
+		assert.NoError(t, err)
+
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		expectTryLock(mock, false)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT max(version_id) FROM goose_db_version")).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(latest))
+
+		err = Apply(context.Background(), db, zap.NewNop())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestApplied(t *testing.T) {
+	t.Run("should fail until the database reaches the newest migration", func(t *testing.T) {
+		assert.NoError(t, setup())
+		latest, err := latestVersion()
+		assert.NoError(t, err)
+
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		check := Applied(db)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT max(version_id) FROM goose_db_version")).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(latest - 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT max(version_id) FROM goose_db_version")).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(latest))
+
+		assert.ErrorContains(t, check(context.Background()), "database is at migration")
+		assert.NoError(t, check(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
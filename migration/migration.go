@@ -1,8 +1,10 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"fmt"
 
 	"github.com/pressly/goose/v3"
 )
@@ -11,23 +13,49 @@ import (
 var embedMigrations embed.FS
 
 func ApplyMigrations(db *sql.DB) error {
-	goose.SetBaseFS(embedMigrations)
-	if err := goose.SetDialect("postgres"); err != nil {
+	if err := setup(); err != nil {
 		return err
 	}
-	if err := goose.Up(db, "."); err != nil {
+	return goose.Up(db, ".")
+}
+
+// RollbackMigrations undoes every applied migration, not just the newest,
+// so tests that apply them again start from an empty database.
+func RollbackMigrations(db *sql.DB) error {
+	if err := setup(); err != nil {
 		return err
 	}
-	return nil
+	return goose.DownTo(db, ".", 0)
 }
 
-func RollbackMigrations(db *sql.DB) error {
+// setup points goose, which keeps its settings in globals, at the embedded
+// migrations.
+func setup() error {
 	goose.SetBaseFS(embedMigrations)
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
+	return goose.SetDialect("postgres")
+}
+
+// Applied returns a check, for the readiness probe, that fails until the
+// database is at the newest embedded migration. The newest version is read
+// once here, as probes run concurrently and goose's settings are globals.
+func Applied(db *sql.DB) func(ctx context.Context) error {
+	var latest int64
+	err := setup()
+	if err == nil {
+		latest, err = latestVersion()
 	}
-	if err := goose.Down(db, "."); err != nil {
-		return err
+	return func(ctx context.Context) error {
+		if err != nil {
+			return err
+		}
+
+		current, err := currentVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if current < latest {
+			return fmt.Errorf("database is at migration %d of %d", current, latest)
+		}
+		return nil
 	}
-	return nil
 }
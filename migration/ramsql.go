@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ramsqlMigrations is the schema of the embedded ramsql database. ramsql
+// understands too little SQL for the goose migrations, so ramsql/ restates
+// the tables the spender and transaction stores use in the subset it does.
+// Keep it in step when a migration changes those tables.
+//
+//go:embed ramsql/*.sql
+var ramsqlMigrations embed.FS
+
+// ApplyFor brings the schema up to date the way driver needs: Apply for
+// Postgres, or the ramsql schema for the embedded database. ramsql lives in
+// the process and starts empty, so there is no version to track and no
+// other replica to wait for.
+func ApplyFor(ctx context.Context, db *sql.DB, driver string, logger *zap.Logger) error {
+	if driver != "ramsql" {
+		return Apply(ctx, db, logger)
+	}
+
+	files, err := fs.Glob(ramsqlMigrations, "ramsql/*.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		b, err := ramsqlMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, stripComments(string(b))); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		logger.Info("ramsql schema applied", zap.String("file", name))
+	}
+	return nil
+}
+
+// stripComments drops "--" lines, which the ramsql lexer rejects.
+func stripComments(query string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(query, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,7 @@
+-- spender as 01_init.sql and 10_constraints.sql leave it. ramsql has no
+-- expression indexes, so the email is unique but case-sensitive here.
+CREATE TABLE IF NOT EXISTS spender (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL UNIQUE
+);
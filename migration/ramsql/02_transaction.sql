@@ -0,0 +1,13 @@
+-- transaction as 02_transaction.sql through 10_constraints.sql leave it.
+-- ramsql has neither DECIMAL, foreign keys nor CHECK, so amount is a FLOAT
+-- and spender_id, transaction_type and amount are not checked.
+CREATE TABLE IF NOT EXISTS transaction (
+	id BIGSERIAL PRIMARY KEY,
+	date TIMESTAMP WITH TIME ZONE,
+	amount FLOAT DEFAULT 0,
+	category VARCHAR(50) DEFAULT '',
+	transaction_type VARCHAR(20) DEFAULT '',
+	note VARCHAR(255) DEFAULT '',
+	image_url VARCHAR(255) DEFAULT '',
+	spender_id BIGINT NOT NULL
+);
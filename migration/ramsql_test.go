@@ -0,0 +1,33 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/proullon/ramsql/driver"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestApplyForRamSQL(t *testing.T) {
+	t.Run("should create the store tables and be safe to run again", func(t *testing.T) {
+		db, _ := sql.Open("ramsql", "TestApplyForRamSQL")
+		defer db.Close()
+
+		assert.NoError(t, ApplyFor(context.Background(), db, "ramsql", zap.NewNop()))
+		assert.NoError(t, ApplyFor(context.Background(), db, "ramsql", zap.NewNop()))
+
+		_, err := db.Exec(`INSERT INTO spender (name, email) VALUES ($1, $2)`, "HongJot", "hong@jot.ok")
+		assert.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO transaction (spender_id, date, amount, transaction_type) VALUES ($1, $2, $3, $4)`, 1, time.Now(), 150.5, "expense")
+		assert.NoError(t, err)
+	})
+}
+
+func TestStripComments(t *testing.T) {
+	got := stripComments("-- about t\nCREATE TABLE t (\n  -- the key\n  id BIGSERIAL PRIMARY KEY\n);")
+
+	assert.Equal(t, "CREATE TABLE t (\n  id BIGSERIAL PRIMARY KEY\n);\n", got)
+}
@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+//go:embed seeds
+var embedSeeds embed.FS
+
+// Seed applies the data set in seeds/<set>, e.g. "dev". Seeds are goose
+// migrations kept apart from the schema: they run only when asked for and
+// are recorded in a version table of their own, so each file applies once.
+func Seed(ctx context.Context, db *sql.DB, set string) error {
+	if !name.MatchString(set) {
+		return fmt.Errorf("seed set %q must be lower case letters, digits and underscores", set)
+	}
+	dir := "seeds/" + set
+	if _, err := fs.Stat(embedSeeds, dir); err != nil {
+		return fmt.Errorf("no seed set %q in migration/seeds", set)
+	}
+	seeds, err := fs.Sub(embedSeeds, dir)
+	if err != nil {
+		return err
+	}
+
+	store, err := database.NewStore(database.DialectPostgres, "goose_seed_"+set)
+	if err != nil {
+		return err
+	}
+	p, err := goose.NewProvider("", db, seeds, goose.WithStore(store))
+	if err != nil {
+		return err
+	}
+	_, err = p.Up(ctx)
+	return err
+}
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO spender (id, name, email) VALUES
+(1, 'John Doe', 'john001@gmail.com'),
+(2, 'Jane Doe', 'jane002@gmail.com')
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval(pg_get_serial_sequence('spender', 'id'), (SELECT max(id) FROM spender));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM spender WHERE id IN (1, 2);
+-- +goose StatementEnd
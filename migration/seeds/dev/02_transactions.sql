@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO transaction (id, date, spender_id, amount, category, transaction_type, note, image_url) VALUES
+(1, '2021-07-01 00:00:00', 1, 100, 'Food', 'expense', 'Lunch', ''),
+(2, '2021-07-02 00:00:00', 1, 200, 'Transport', 'income', 'Bus', ''),
+(3, '2021-07-03 00:00:00', 1, 300, 'Food', 'income', 'Dinner', ''),
+(4, '2021-07-04 00:00:00', 1, 400, 'Transport', 'income', 'Taxi', ''),
+(5, '2021-07-05 00:00:00', 1, 500, 'Food', 'income', 'Breakfast', ''),
+(6, '2021-07-06 00:00:00', 1, 600, 'Transport', 'income', 'Bus', ''),
+(7, '2021-07-07 00:00:00', 1, 700, 'Food', 'income', 'Lunch', ''),
+(8, '2021-07-08 00:00:00', 2, 800, 'Transport', 'income', 'Bus', ''),
+(9, '2021-07-09 00:00:00', 2, 900, 'Food', 'income', 'Dinner', ''),
+(10, '2021-07-10 00:00:00', 2, 1000, 'Transport', 'income', 'Taxi', ''),
+(11, '2021-07-11 00:00:00', 2, 1100, 'Food', 'income', 'Breakfast', ''),
+(12, '2021-07-12 00:00:00', 2, 1200, 'Transport', 'expense', 'Bus', ''),
+(13, '2021-07-13 00:00:00', 1, 1300, 'Food', 'expense', 'Lunch', ''),
+(14, '2021-07-14 00:00:00', 1, 1400, 'Transport', 'expense', 'Bus', ''),
+(15, '2021-07-15 00:00:00', 1, 1500, 'Food', 'expense', 'Dinner', ''),
+(16, '2021-07-16 00:00:00', 1, 1600, 'Transport', 'expense', 'Taxi', '')
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval(pg_get_serial_sequence('transaction', 'id'), (SELECT max(id) FROM transaction));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM transaction WHERE id BETWEEN 1 AND 16;
+-- +goose StatementEnd